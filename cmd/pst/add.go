@@ -0,0 +1,82 @@
+// cmd/pst/add.go
+
+package pst
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"github.com/spf13/cobra"
+)
+
+var addCmd = &cobra.Command{
+    Use:   "add <collection-name> [path/to/file/or/folder...]",
+    Short: "Add more files or folders to an existing collection",
+    Args:  cobra.MinimumNArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        collectionName := args[0]
+        if err := collections.IsValidCollectionName(collectionName); err != nil {
+            return err
+        }
+        paths := args[1:]
+
+        // If no paths are specified, default to the current directory
+        if len(paths) == 0 {
+            paths = append(paths, ".")
+        }
+
+        // Settings are only stored for a collection that exists, which add doesn't create
+        if _, err := os.Stat(collections.GetCollectionPath(collectionName)); os.IsNotExist(err) {
+            return fmt.Errorf("collection %s does not exist, use init to create it", collectionName)
+        }
+
+        // Store ignore patterns first so they apply to the added paths
+        if len(ignorePatterns) > 0 {
+            if err := collections.AddIgnorePatterns(collectionName, ignorePatterns); err != nil {
+                return fmt.Errorf("failed to save ignore patterns: %w", err)
+            }
+        }
+        if symlinkPolicy != "" {
+            if err := collections.SetSymlinkPolicy(collectionName, symlinkPolicy); err != nil {
+                return fmt.Errorf("failed to save symlink policy: %w", err)
+            }
+        }
+        if useGit || gitRemote != "" {
+            if err := collections.EnableGit(collectionName, gitRemote); err != nil {
+                return fmt.Errorf("failed to set up git for collection %s: %w", collectionName, err)
+            }
+        }
+        if len(rewriteRules) > 0 {
+            rewrites, err := parseRewrites(rewriteRules)
+            if err != nil {
+                return err
+            }
+            if err := collections.SetCollectionRewrites(collectionName, rewrites); err != nil {
+                return fmt.Errorf("failed to save rewrite rules: %w", err)
+            }
+        }
+        if cmd.Flags().Changed("header") {
+            if err := collections.SetHeader(collectionName, provenanceHeader); err != nil {
+                return fmt.Errorf("failed to save header setting: %w", err)
+            }
+        }
+
+        files, err := collections.AddPaths(collectionName, paths, force)
+        if err != nil {
+            addResult(collections.Result{Collection: collectionName, Action: "add", Files: files})
+            return fmt.Errorf("failed to add files to collection: %w", err)
+        }
+
+        revision, err := recordRevision(collectionName, "Add files")
+        addResult(collections.Result{Collection: collectionName, Action: "add", Files: files, Revision: revision})
+        if err != nil {
+            return err
+        }
+
+        for _, file := range files {
+            printText("Added %s to collection %s\n", file.Path, collectionName)
+        }
+        return nil
+    },
+}
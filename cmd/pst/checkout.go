@@ -0,0 +1,42 @@
+// cmd/pst/checkout.go
+
+package pst
+
+import (
+	"fmt"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"github.com/spf13/cobra"
+)
+
+var checkoutCmd = &cobra.Command{
+    Use:   "checkout <collection-name> <revision>",
+    Short: "Restore the central collection to an earlier revision",
+    Args:  cobra.ExactArgs(2),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        collectionName := args[0]
+        if err := collections.IsValidCollectionName(collectionName); err != nil {
+            return err
+        }
+
+        if !confirm("Restore the central files of %s to revision %s?", collectionName, args[1]) {
+            return fmt.Errorf("checkout aborted: not confirmed")
+        }
+
+        revision, err := collections.CheckoutRevision(collectionName, args[1])
+        if err != nil {
+            return fmt.Errorf("failed to check out revision %s: %w", args[1], err)
+        }
+
+        if revision == nil {
+            printText("Collection %s is already at revision %s.\n", collectionName, args[1])
+            return nil
+        }
+        addResult(collections.Result{Collection: collectionName, Action: "checkout", Files: revision.Changes, Revision: summarizeRevision(revision)})
+        for _, file := range revision.Changes {
+            printText("%s %s\n", file.Action, file.Path)
+        }
+        printText("Collection %s restored to revision %s as %s.\n", collectionName, args[1], revision.ShortID())
+        return nil
+    },
+}
@@ -0,0 +1,127 @@
+// cmd/pst/config.go
+
+package pst
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/forsvunnet/project-sync-tool/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// settings holds the configuration file loaded when the command started.
+var settings config.Config
+
+var configCmd = &cobra.Command{
+    Use:   "config",
+    Short: "Read and change the settings in the configuration file",
+}
+
+var configGetCmd = &cobra.Command{
+    Use:   "get <key>",
+    Short: "Print the value of a setting",
+    Args:  cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        value, err := settings.Get(args[0])
+        if err != nil {
+            return err
+        }
+        addSetting(args[0], value)
+        printText("%s\n", value)
+        return nil
+    },
+}
+
+var configSetCmd = &cobra.Command{
+    Use:   "set <key> [value...]",
+    Short: "Change a setting, or reset it to its default when no value is given",
+    Args:  cobra.MinimumNArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        if err := settings.Set(args[0], args[1:]); err != nil {
+            return err
+        }
+        if err := config.Save(settings); err != nil {
+            return err
+        }
+        printText("Updated %s in %s\n", args[0], config.FilePath())
+        return nil
+    },
+}
+
+var configListCmd = &cobra.Command{
+    Use:   "list",
+    Short: "List every setting with its value",
+    Args:  cobra.NoArgs,
+    RunE: func(cmd *cobra.Command, args []string) error {
+        for _, setting := range config.Settings {
+            value, err := settings.Get(setting.Key)
+            if err != nil {
+                return err
+            }
+            addSetting(setting.Key, value)
+            printText("%-18s %s\n", setting.Key, strings.ReplaceAll(value, "\n", ", "))
+        }
+        return nil
+    },
+}
+
+var configEditCmd = &cobra.Command{
+    Use:   "edit",
+    Short: "Open the configuration file in the configured editor",
+    Args:  cobra.NoArgs,
+    RunE: func(cmd *cobra.Command, args []string) error {
+        if err := os.MkdirAll(config.Dir(), os.ModePerm); err != nil {
+            return fmt.Errorf("failed to create configuration directory: %w", err)
+        }
+        if err := runTool(editorCommand(), config.FilePath()); err != nil {
+            return fmt.Errorf("failed to edit configuration: %w", err)
+        }
+        _, err := config.Load()
+        return err
+    },
+}
+
+// addSetting records the value of a setting for structured output.
+func addSetting(key, value string) {
+    if output.Settings == nil {
+        output.Settings = map[string]string{}
+    }
+    output.Settings[key] = value
+}
+
+// editorCommand returns the editor from the configuration, $VISUAL or $EDITOR, falling back to vi.
+func editorCommand() string {
+    for _, editor := range []string{settings.Editor, os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+        if editor != "" {
+            return editor
+        }
+    }
+    return "vi"
+}
+
+// runTool runs a configured command line with extra arguments, attached to the terminal.
+func runTool(command string, args ...string) error {
+    fields := strings.Fields(command)
+    if len(fields) == 0 {
+        return fmt.Errorf("no command configured")
+    }
+    tool := exec.Command(fields[0], append(fields[1:], args...)...)
+    tool.Stdin, tool.Stdout, tool.Stderr = os.Stdin, os.Stdout, os.Stderr
+    return tool.Run()
+}
+
+// confirm asks the user to confirm an action when the confirm setting is on.
+// Anything but an explicit yes, including the end of input, declines.
+func confirm(format string, args ...interface{}) bool {
+    if !settings.Confirm {
+        return true
+    }
+    fmt.Fprintf(os.Stderr, format+" [y/N] ", args...)
+    answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+    answer = strings.ToLower(strings.TrimSpace(answer))
+    return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,233 @@
+// cmd/pst/diff.go
+
+package pst
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"github.com/spf13/cobra"
+)
+
+// ANSI colors used for diff output on a terminal
+const (
+    colorReset = "\033[0m"
+    colorRed   = "\033[31m"
+    colorGreen = "\033[32m"
+    colorCyan  = "\033[36m"
+    colorBold  = "\033[1m"
+)
+
+var diffCmd = &cobra.Command{
+    Use:   "diff [collection-name...] [-- path...]",
+    Short: "Show differences between project files and their central copies",
+    RunE: func(cmd *cobra.Command, args []string) error {
+        cwd, err := os.Getwd()
+        if err != nil {
+            return fmt.Errorf("failed to get current working directory: %w", err)
+        }
+
+        // Arguments after "--" limit the diff to the given paths
+        collectionsToDiff := args
+        paths := []string{}
+        if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+            collectionsToDiff = args[:dash]
+            for _, path := range args[dash:] {
+                paths = append(paths, filepath.Clean(path))
+            }
+        }
+        if len(collectionsToDiff) == 0 {
+            collectionsToDiff, err = collections.ScanForCollections(cwd)
+            if err != nil {
+                return fmt.Errorf("failed to scan for collections: %w", err)
+            }
+        }
+
+        color := isTerminal(os.Stdout)
+        for _, collectionName := range collectionsToDiff {
+            projectPath, err := collections.ResolveProjectPath(collectionName, cwd)
+            if err != nil {
+                return err
+            }
+
+            pairs, err := collections.GetFilePairs(collectionName, projectPath)
+            if err != nil {
+                return fmt.Errorf("failed to list files for collection %s: %w", collectionName, err)
+            }
+
+            result := collections.Result{Collection: collectionName, Project: projectPath, Action: "diff"}
+            for _, pair := range pairs {
+                if relPath, _ := filepath.Rel(cwd, pair.ProjectPath); !matchesPaths(relPath, paths) {
+                    continue
+                }
+
+                // By default show how the project differs from central; --reverse shows the opposite
+                fromName, from, err := readDiffSide("central", pair.RelPath, pair.CentralPath)
+                if err != nil {
+                    return err
+                }
+                if from != nil {
+                    from = pair.Render(from)
+                }
+                toName, to, err := readDiffSide("project", pair.RelPath, pair.ProjectPath)
+                if err != nil {
+                    return err
+                }
+                if to != nil {
+                    to = pair.StripHeader(to)
+                }
+                central, project := from, to
+                if reverse {
+                    fromName, from, toName, to = toName, to, fromName, from
+                }
+
+                if bytes.Equal(from, to) {
+                    continue
+                }
+                result.Files = append(result.Files, collections.FileResult{Path: pair.RelPath, Action: "differs"})
+
+                // A configured diff tool takes over displaying the differences
+                if settings.DiffTool != "" && !structuredOutput() {
+                    if err := runDiffTool(pair, central, project, reverse); err != nil {
+                        return err
+                    }
+                    continue
+                }
+
+                if collections.IsBinary(from) || collections.IsBinary(to) {
+                    printText("Binary files %s and %s differ\n", fromName, toName)
+                    printText("  %s: %d bytes, sha256 %x\n", fromName, len(from), sha256.Sum256(from))
+                    printText("  %s: %d bytes, sha256 %x\n", toName, len(to), sha256.Sum256(to))
+                    continue
+                }
+                printText("%s", colorizeDiff(collections.UnifiedDiff(fromName, toName, from, to), color))
+            }
+            addResult(result)
+        }
+
+        return nil
+    },
+}
+
+// runDiffTool runs the configured diff tool on the central and project file, in that order unless reversed.
+// Missing files are passed as /dev/null, and the exit status of the tool is ignored as diff tools report differences through it.
+// Files whose compared content differs from what is on disk, such as a central file with template variables
+// or a project file with a provenance header, are passed as compared, in temporary files.
+func runDiffTool(pair collections.FilePair, central, project []byte, reverse bool) error {
+    from, err := comparedFile("central", pair.CentralPath, central)
+    if err != nil {
+        return err
+    }
+    defer removeTemp(from, pair.CentralPath)
+    to, err := comparedFile("project", pair.ProjectPath, project)
+    if err != nil {
+        return err
+    }
+    defer removeTemp(to, pair.ProjectPath)
+    if reverse {
+        from, to = to, from
+    }
+    if _, err := os.Stat(from); os.IsNotExist(err) {
+        from = os.DevNull
+    }
+    if _, err := os.Stat(to); os.IsNotExist(err) {
+        to = os.DevNull
+    }
+
+    var exitErr *exec.ExitError
+    if err := runTool(settings.DiffTool, from, to); err != nil && !errors.As(err, &exitErr) {
+        return fmt.Errorf("failed to run diff tool: %w", err)
+    }
+    return nil
+}
+
+// comparedFile returns path, or a temporary copy of it holding data if that isn't its content.
+func comparedFile(side, path string, data []byte) (string, error) {
+    if original, err := os.ReadFile(path); err != nil || bytes.Equal(original, data) {
+        return path, nil
+    }
+    temp, err := os.CreateTemp("", side+"-*-"+filepath.Base(path))
+    if err != nil {
+        return "", fmt.Errorf("failed to write %s file for diff tool: %w", side, err)
+    }
+    _, err = temp.Write(data)
+    if closeErr := temp.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        os.Remove(temp.Name())
+        return "", fmt.Errorf("failed to write %s file for diff tool: %w", side, err)
+    }
+    return temp.Name(), nil
+}
+
+// removeTemp removes a temporary file made by comparedFile for path.
+func removeTemp(file, path string) {
+    if file != path {
+        os.Remove(file)
+    }
+}
+
+// readDiffSide reads one side of a diff, using /dev/null as the name of a missing file.
+func readDiffSide(side, relPath, path string) (string, []byte, error) {
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return "/dev/null", nil, nil
+    } else if err != nil {
+        return "", nil, fmt.Errorf("failed to read %s file %s: %w", side, path, err)
+    }
+    return side + "/" + filepath.ToSlash(relPath), data, nil
+}
+
+// matchesPaths reports whether relPath is one of paths or inside one of them. An empty list matches everything.
+func matchesPaths(relPath string, paths []string) bool {
+    if len(paths) == 0 {
+        return true
+    }
+    for _, path := range paths {
+        if path == "." || relPath == path || strings.HasPrefix(relPath, path+string(filepath.Separator)) {
+            return true
+        }
+    }
+    return false
+}
+
+// colorizeDiff adds ANSI colors to a unified diff when enabled.
+func colorizeDiff(diff string, enabled bool) string {
+    if !enabled {
+        return diff
+    }
+
+    var out strings.Builder
+    for _, line := range strings.SplitAfter(diff, "\n") {
+        switch {
+        case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+            out.WriteString(colorBold + strings.TrimSuffix(line, "\n") + colorReset + "\n")
+        case strings.HasPrefix(line, "@@"):
+            out.WriteString(colorCyan + strings.TrimSuffix(line, "\n") + colorReset + "\n")
+        case strings.HasPrefix(line, "-"):
+            out.WriteString(colorRed + strings.TrimSuffix(line, "\n") + colorReset + "\n")
+        case strings.HasPrefix(line, "+"):
+            out.WriteString(colorGreen + strings.TrimSuffix(line, "\n") + colorReset + "\n")
+        default:
+            out.WriteString(line)
+        }
+    }
+    return out.String()
+}
+
+// isTerminal reports whether the file is attached to a terminal.
+func isTerminal(file *os.File) bool {
+    info, err := file.Stat()
+    if err != nil {
+        return false
+    }
+    return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,182 @@
+// cmd/pst/hook.go
+
+package pst
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"github.com/spf13/cobra"
+)
+
+var projectHook bool
+
+var hookCmd = &cobra.Command{
+    Use:   "hook",
+    Short: "Manage commands run before and after require, push and sync",
+}
+
+var hookAddCmd = &cobra.Command{
+    Use:   "add <collection-name> <hook> <command...>",
+    Short: "Add a command to a hook: pre-require, post-require, pre-push, post-push, pre-sync or post-sync",
+    Args:  cobra.MinimumNArgs(3),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        projectPath, err := hookProject(args[0])
+        if err != nil {
+            return err
+        }
+        command := strings.Join(args[2:], " ")
+        if err := collections.AddHook(args[0], projectPath, args[1], command); err != nil {
+            return fmt.Errorf("failed to add hook: %w", err)
+        }
+        printText("Added %s hook to %s: %s\n", args[1], hookScope(args[0], projectPath), command)
+        return nil
+    },
+}
+
+var hookRemoveCmd = &cobra.Command{
+    Use:   "remove <collection-name> <hook>",
+    Short: "Remove every command of a hook",
+    Args:  cobra.ExactArgs(2),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        projectPath, err := hookProject(args[0])
+        if err != nil {
+            return err
+        }
+        if err := collections.RemoveHook(args[0], projectPath, args[1]); err != nil {
+            return fmt.Errorf("failed to remove hook: %w", err)
+        }
+        printText("Removed %s hook from %s\n", args[1], hookScope(args[0], projectPath))
+        return nil
+    },
+}
+
+var hookListCmd = &cobra.Command{
+    Use:   "list <collection-name>",
+    Short: "List the hooks of the configuration file, a collection and the current project, in the order they run",
+    Args:  cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        projectPath, err := currentProject(args[0])
+        if err != nil {
+            return err
+        }
+        collectionHooks, projectHooks, err := collections.GetHooks(args[0], projectPath)
+        if err != nil {
+            return err
+        }
+        collectionScope, projectScope := "collection", "project"
+        if len(collectionHooks.Hooks) > 0 {
+            addSetting("collection trusted", fmt.Sprint(collectionHooks.Trusted))
+        }
+        if len(projectHooks.Hooks) > 0 {
+            addSetting("project trusted", fmt.Sprint(projectHooks.Trusted))
+        }
+        if !collectionHooks.Trusted {
+            collectionScope = "untrusted"
+        }
+        if !projectHooks.Trusted {
+            projectScope = "untrusted"
+        }
+        if !collectionHooks.Trusted || !projectHooks.Trusted {
+            printText("Some hooks are not trusted and won't run. Run pst hook trust %s to allow them.\n", args[0])
+        }
+
+        for _, hook := range collections.HookNames {
+            for scope, hooks := range map[string]map[string][]string{"collection": collectionHooks.Hooks, "project": projectHooks.Hooks} {
+                if len(hooks[hook]) > 0 {
+                    addSetting(scope+" "+hook, strings.Join(hooks[hook], "\n"))
+                }
+            }
+            for _, command := range settings.Hooks[hook] {
+                printText("%-13s config      %s\n", hook, command)
+            }
+            for _, command := range collectionHooks.Hooks[hook] {
+                printText("%-13s %-11s %s\n", hook, collectionScope, command)
+            }
+            for _, command := range projectHooks.Hooks[hook] {
+                printText("%-13s %-11s %s\n", hook, projectScope, command)
+            }
+        }
+        return nil
+    },
+}
+
+var hookTrustCmd = &cobra.Command{
+    Use:   "trust <collection-name>",
+    Short: "Allow the hooks of a collection and of the current project's manifest to run, as they are now",
+    Args:  cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        projectPath, err := currentProject(args[0])
+        if err != nil {
+            return err
+        }
+        collectionHooks, projectHooks, err := collections.TrustHooks(args[0], projectPath)
+        if err != nil {
+            return fmt.Errorf("failed to trust hooks: %w", err)
+        }
+        for _, hook := range collections.HookNames {
+            for _, command := range collectionHooks[hook] {
+                printText("%-13s collection  %s\n", hook, command)
+            }
+            for _, command := range projectHooks[hook] {
+                printText("%-13s project     %s\n", hook, command)
+            }
+        }
+        printText("Trusted the hooks of %s\n", hookScope(args[0], projectPath))
+        return nil
+    },
+}
+
+// hookProject returns the project whose manifest holds the hooks being changed with --project, or else an empty path,
+// for hooks of the collection itself.
+func hookProject(collectionName string) (string, error) {
+    if !projectHook {
+        return "", collections.IsValidCollectionName(collectionName)
+    }
+    return currentProject(collectionName)
+}
+
+// currentProject returns where the collection is installed in the project of the working directory.
+func currentProject(collectionName string) (string, error) {
+    if err := collections.IsValidCollectionName(collectionName); err != nil {
+        return "", err
+    }
+    cwd, err := os.Getwd()
+    if err != nil {
+        return "", fmt.Errorf("failed to get current working directory: %w", err)
+    }
+    return collections.ResolveProjectPath(collectionName, cwd)
+}
+
+// hookScope describes where hooks are kept, for messages.
+func hookScope(collectionName, projectPath string) string {
+    if projectPath != "" {
+        return fmt.Sprintf("collection %s in %s", collectionName, projectPath)
+    }
+    return "collection " + collectionName
+}
+
+// runHooks runs a hook for the given project files.
+func runHooks(hook, collectionName, projectPath string, files []string) error {
+    if err := collections.RunHooks(hook, collectionName, projectPath, files); err != nil {
+        if strings.HasPrefix(hook, "pre-") {
+            return fmt.Errorf("%s aborted: %w", strings.TrimPrefix(hook, "pre-"), err)
+        }
+        return err
+    }
+    return nil
+}
+
+// runResultHooks runs a hook for the project files of a command's results.
+func runResultHooks(hook, collectionName, projectPath string, results []collections.FileResult) error {
+    if len(results) == 0 {
+        return nil
+    }
+    files, err := collections.ResultFiles(collectionName, projectPath, results)
+    if err != nil {
+        return err
+    }
+    return runHooks(hook, collectionName, projectPath, files)
+}
@@ -24,13 +24,69 @@ var initCmd = &cobra.Command{
             paths = append(paths, ".")
         }
 
+        if dryRun {
+            if len(ignorePatterns) > 0 || symlinkPolicy != "" || useGit || gitRemote != "" || len(rewriteRules) > 0 || cmd.Flags().Changed("header") {
+                return fmt.Errorf("--dry-run can't be combined with --ignore, --symlinks, --git, --rewrite or --header, which are stored first")
+            }
+            plan, err := collections.PlanAddToCollection(collectionName, paths, force)
+            if err != nil {
+                return fmt.Errorf("failed to plan adding files to collection: %w", err)
+            }
+            addPlan("init", plan)
+            return nil
+        }
+
+        // Store ignore patterns first so they apply to the initial copy. Rebuilding a collection with
+        // --force replaces the stored patterns instead of adding to them
+        if force {
+            if err := collections.SetIgnorePatterns(collectionName, ignorePatterns); err != nil {
+                return fmt.Errorf("failed to save ignore patterns: %w", err)
+            }
+        } else if len(ignorePatterns) > 0 {
+            if err := collections.AddIgnorePatterns(collectionName, ignorePatterns); err != nil {
+                return fmt.Errorf("failed to save ignore patterns: %w", err)
+            }
+        }
+        if symlinkPolicy != "" {
+            if err := collections.SetSymlinkPolicy(collectionName, symlinkPolicy); err != nil {
+                return fmt.Errorf("failed to save symlink policy: %w", err)
+            }
+        }
+        if useGit || gitRemote != "" {
+            if err := collections.EnableGit(collectionName, gitRemote); err != nil {
+                return fmt.Errorf("failed to set up git for collection %s: %w", collectionName, err)
+            }
+        }
+        if len(rewriteRules) > 0 {
+            rewrites, err := parseRewrites(rewriteRules)
+            if err != nil {
+                return err
+            }
+            if err := collections.SetCollectionRewrites(collectionName, rewrites); err != nil {
+                return fmt.Errorf("failed to save rewrite rules: %w", err)
+            }
+        }
+        if cmd.Flags().Changed("header") {
+            if err := collections.SetHeader(collectionName, provenanceHeader); err != nil {
+                return fmt.Errorf("failed to save header setting: %w", err)
+            }
+        }
+
         // Call the internal collections package to handle sharing
-        err := collections.AddToCollection(collectionName, paths, force)
+        files, err := collections.AddToCollection(collectionName, paths, force)
         if err != nil {
+            addResult(collections.Result{Collection: collectionName, Action: "init", Files: files})
             return fmt.Errorf("failed to add files to collection: %w", err)
         }
 
-        fmt.Printf("Files added to collection %s successfully.\n", collectionName)
+        // Snapshot the collection so later changes can be traced and undone
+        revision, err := recordRevision(collectionName, "Initialize collection")
+        addResult(collections.Result{Collection: collectionName, Action: "init", Files: files, Revision: revision})
+        if err != nil {
+            return err
+        }
+
+        printText("Files added to collection %s successfully.\n", collectionName)
         return nil
     },
 }
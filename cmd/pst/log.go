@@ -0,0 +1,79 @@
+// cmd/pst/log.go
+
+package pst
+
+import (
+	"fmt"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"github.com/spf13/cobra"
+)
+
+var message string
+
+var logCmd = &cobra.Command{
+    Use:   "log <collection-name>",
+    Short: "List the revisions of a collection, newest first",
+    Args:  cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        collectionName := args[0]
+        if err := collections.IsValidCollectionName(collectionName); err != nil {
+            return err
+        }
+
+        // Git-backed collections read their history from git
+        git, err := collections.IsGitBacked(collectionName)
+        if err != nil {
+            return err
+        }
+        var history []collections.Revision
+        if git {
+            history, err = collections.GitLog(collectionName)
+        } else {
+            history, err = collections.GetHistory(collectionName)
+        }
+        if err != nil {
+            return err
+        }
+
+        for _, revision := range history {
+            addResult(collections.Result{Collection: collectionName, Action: "log", Revision: summarizeRevision(&revision)})
+
+            printText("revision %s\n", revision.ShortID())
+            printText("Author: %s\n", revision.Author)
+            printText("Date:   %s\n\n", revision.Time.Local().Format("2006-01-02 15:04:05 -0700"))
+            printText("    %s\n\n", revision.Message)
+            for _, file := range revision.Changes {
+                printText("    %-8s %s\n", file.Action, file.Path)
+            }
+            printText("\n")
+        }
+        return nil
+    },
+}
+
+// recordRevision snapshots a collection after it changed, using the --message flag if given.
+func recordRevision(collectionName, defaultMessage string) (*collections.Revision, error) {
+    if message != "" {
+        defaultMessage = message
+    }
+    revision, err := collections.RecordRevision(collectionName, defaultMessage)
+    if err != nil {
+        return nil, fmt.Errorf("failed to record revision for collection %s: %w", collectionName, err)
+    }
+    if revision != nil {
+        printText("Recorded revision %s of collection %s\n", revision.ShortID(), collectionName)
+    }
+    return summarizeRevision(revision), nil
+}
+
+// summarizeRevision drops the file tree from a revision for output.
+func summarizeRevision(revision *collections.Revision) *collections.Revision {
+    if revision == nil {
+        return nil
+    }
+    summary := *revision
+    summary.Files = nil
+    summary.Modes = nil
+    return &summary
+}
@@ -0,0 +1,93 @@
+// cmd/pst/output.go
+
+package pst
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"gopkg.in/yaml.v3"
+)
+
+var outputFormat string
+
+// report is the structured output of a single command run.
+type report struct {
+    Command  string               `json:"command" yaml:"command"`
+    Results  []collections.Result `json:"results" yaml:"results"`
+    Settings map[string]string    `json:"settings,omitempty" yaml:"settings,omitempty"`
+    Error    string               `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+var output = report{Results: []collections.Result{}}
+
+// validateOutputFormat checks the value of the --output flag.
+func validateOutputFormat() error {
+    switch outputFormat {
+    case "text", "json", "yaml":
+        return nil
+    }
+    return fmt.Errorf("unsupported output format %q: use text, json or yaml", outputFormat)
+}
+
+// structuredOutput reports whether results are rendered as JSON or YAML instead of text.
+func structuredOutput() bool {
+    return outputFormat == "json" || outputFormat == "yaml"
+}
+
+// addResult records a collection result for structured output.
+func addResult(result collections.Result) {
+    if result.Files == nil {
+        result.Files = []collections.FileResult{}
+    }
+    output.Results = append(output.Results, result)
+}
+
+// addPlan reports the planned changes of a dry run.
+func addPlan(action string, plan *collections.Plan) {
+    addResult(collections.Result{Collection: plan.Collection, Project: plan.Project, Action: action, Files: plan.Changes(), DryRun: true})
+
+    printText("Dry run of %s for collection %s in %s:\n", action, plan.Collection, plan.Project)
+    if len(plan.Files) == 0 {
+        printText("  nothing to do\n")
+    }
+    for _, file := range plan.Files {
+        printText("  %-10s %s\n", file.Action, file.Path)
+    }
+}
+
+// printText prints a human-readable message when the text output format is selected.
+func printText(format string, args ...interface{}) {
+    if !structuredOutput() {
+        fmt.Printf(format, args...)
+    }
+}
+
+// writeReport renders the collected results in the selected structured format.
+func writeReport(command string, runErr error) error {
+    if !structuredOutput() {
+        return nil
+    }
+
+    output.Command = command
+    if runErr != nil {
+        output.Error = runErr.Error()
+    }
+
+    var data []byte
+    var err error
+    if outputFormat == "json" {
+        data, err = json.MarshalIndent(output, "", "  ")
+        data = append(data, '\n')
+    } else {
+        data, err = yaml.Marshal(output)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to render %s output: %w", outputFormat, err)
+    }
+
+    _, err = os.Stdout.Write(data)
+    return err
+}
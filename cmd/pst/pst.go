@@ -3,24 +3,158 @@
 package pst
 
 import (
+    "os"
+    "strings"
+
+    "github.com/forsvunnet/project-sync-tool/internal/collections"
+    "github.com/forsvunnet/project-sync-tool/internal/config"
     "github.com/spf13/cobra"
 )
 
 var targetDir string
 var force bool
+var global bool
+var update bool
+var filesOnly bool
+var reverse bool
+var prune bool
+var mergeConflicts bool
+var deleteCopies bool
+var pathMappings []string
+var templateVariables []string
+var rewriteRules []string
+var provenanceHeader bool
+var ignorePatterns []string
+var dryRun bool
+var symlinkPolicy string
+var preserveTimes bool
+var storeRoot string
+var useGit bool
+var gitRemote string
 
 // Execute initializes the root command and adds subcommands
 func Execute() error {
-    rootCmd := &cobra.Command{Use: "pst"}
+    rootCmd := &cobra.Command{
+        Use: "pst",
+        PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+            // Settings from the configuration file apply unless overridden by a flag
+            var err error
+            if settings, err = config.Load(); err != nil {
+                return err
+            }
+            if settings.Output != "" && !cmd.Flag("output").Changed {
+                outputFormat = settings.Output
+            }
+            collections.PreserveTimes = preserveTimes || settings.PreserveTimes
+            if err := validateOutputFormat(); err != nil {
+                return err
+            }
+            if structuredOutput() {
+                // Hooks must not mix their output into the report
+                collections.HookOutput = os.Stderr
+            }
+            if storeRoot != "" {
+                if err := config.SetStore(storeRoot); err != nil {
+                    return err
+                }
+            }
+
+            // Journal the files each command changes so it can be undone
+            if cmd != undoCmd {
+                collections.BeginOperation("pst " + strings.Join(os.Args[1:], " "))
+            }
+            return nil
+        },
+    }
+    rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "text", "Output format: text, json or yaml")
+    rootCmd.PersistentFlags().StringVar(&storeRoot, "store", "", "Directory holding collections and their history, overriding PST_HOME and the configuration file")
+    rootCmd.PersistentFlags().BoolVar(&preserveTimes, "preserve-times", false, "Keep the modification times of copied files")
     rootCmd.AddCommand(initCmd)
     rootCmd.AddCommand(requireCmd)
     rootCmd.AddCommand(pushCmd)
-    return rootCmd.Execute()
+    rootCmd.AddCommand(syncCmd)
+    rootCmd.AddCommand(statusCmd)
+    rootCmd.AddCommand(diffCmd)
+    rootCmd.AddCommand(addCmd)
+    rootCmd.AddCommand(removeCmd)
+    rootCmd.AddCommand(logCmd)
+    rootCmd.AddCommand(showCmd)
+    rootCmd.AddCommand(checkoutCmd)
+    rootCmd.AddCommand(tagCmd)
+    rootCmd.AddCommand(resolveCmd)
+    rootCmd.AddCommand(undoCmd)
+    rootCmd.AddCommand(configCmd)
+    configCmd.AddCommand(configGetCmd)
+    configCmd.AddCommand(configSetCmd)
+    configCmd.AddCommand(configListCmd)
+    configCmd.AddCommand(configEditCmd)
+    rootCmd.AddCommand(storeCmd)
+    storeCmd.AddCommand(storeAddCmd)
+    storeCmd.AddCommand(storeRemoveCmd)
+    storeCmd.AddCommand(storeListCmd)
+    rootCmd.AddCommand(publishCmd)
+    rootCmd.AddCommand(hookCmd)
+    hookCmd.AddCommand(hookAddCmd)
+    hookCmd.AddCommand(hookRemoveCmd)
+    hookCmd.AddCommand(hookListCmd)
+    hookCmd.AddCommand(hookTrustCmd)
+    rootCmd.AddCommand(fetchCmd)
+
+    cmd, err := rootCmd.ExecuteC()
+    if journalErr := collections.EndOperation(); err == nil {
+        err = journalErr
+    }
+    if reportErr := writeReport(cmd.Name(), err); reportErr != nil {
+        return reportErr
+    }
+    return err
 }
 
 func init() {
     initCmd.Flags().BoolVarP(&force, "force", "f", false, "Forcefully replace existing files in the collection")
+    initCmd.Flags().StringArrayVarP(&ignorePatterns, "ignore", "i", nil, "Gitignore-style pattern for files to leave out of the collection")
     requireCmd.Flags().StringVarP(&targetDir, "target", "t", "", "Specify a target directory to load the collection into")
     requireCmd.Flags().BoolVarP(&force, "force", "f", false, "Forcefully overwrite local files even if they are newer")
     pushCmd.Flags().BoolVarP(&force, "force", "f", false, "Forcefully overwrite central files even if they are newer")
+    syncCmd.Flags().BoolVarP(&global, "global", "g", false, "Sync collections in every registered project")
+    syncCmd.Flags().BoolVarP(&update, "update", "u", false, "Push local changes to central before pulling")
+    syncCmd.Flags().BoolVar(&prune, "prune", false, "Propagate file deletions between projects and central")
+    syncCmd.Flags().BoolVar(&mergeConflicts, "merge", false, "Merge files changed both locally and in central instead of aborting")
+    requireCmd.Flags().StringArrayVarP(&pathMappings, "map", "m", nil, "Install a collection path elsewhere in the project, as <collection-path>=<project-path>")
+    requireCmd.Flags().BoolVar(&prune, "prune", false, "Delete local files that were deleted from central")
+    requireCmd.Flags().StringArrayVar(&rewriteRules, "rewrite", nil, "Use this project's PHP namespace, Go import path or JS module path instead of the collection's, as <php|go|js>=<name>")
+    requireCmd.Flags().StringArrayVar(&templateVariables, "var", nil, "Set the value of a placeholder in central files for this project, as <name>=<value>; an empty value removes it")
+    addCmd.Flags().BoolVarP(&force, "force", "f", false, "Replace files that are already in the collection")
+    addCmd.Flags().StringArrayVarP(&ignorePatterns, "ignore", "i", nil, "Gitignore-style pattern for files to leave out of the collection")
+    removeCmd.Flags().BoolVar(&deleteCopies, "delete-copies", false, "Also delete unmodified copies from every registered project")
+    pushCmd.Flags().BoolVar(&prune, "prune", false, "Delete central files that were deleted from the project")
+    statusCmd.Flags().BoolVar(&filesOnly, "files-only", false, "Only print the paths of tracked files")
+    diffCmd.Flags().BoolVarP(&reverse, "reverse", "R", false, "Show how central differs from the project instead")
+    initCmd.Flags().StringVarP(&message, "message", "m", "", "Describe the revision recorded for this change")
+    pushCmd.Flags().StringVarP(&message, "message", "m", "", "Describe the revision recorded for this change")
+    addCmd.Flags().StringVarP(&message, "message", "m", "", "Describe the revision recorded for this change")
+    removeCmd.Flags().StringVarP(&message, "message", "m", "", "Describe the revision recorded for this change")
+    resolveCmd.Flags().BoolVarP(&force, "force", "f", false, "Resolve files that still contain conflict markers")
+    tagCmd.Flags().BoolVarP(&force, "force", "f", false, "Move the tag if it already exists")
+    undoCmd.Flags().BoolVarP(&listOperations, "list", "l", false, "List the journaled operations instead")
+    undoCmd.Flags().BoolVarP(&force, "force", "f", false, "Restore files even if they changed since the operation")
+    publishCmd.Flags().BoolVarP(&force, "force", "f", false, "Replace the store's history even if it has revisions the collection doesn't")
+    hookAddCmd.Flags().BoolVarP(&projectHook, "project", "p", false, "Add the hook to the current project's manifest instead of the collection")
+    hookRemoveCmd.Flags().BoolVarP(&projectHook, "project", "p", false, "Remove the hook from the current project's manifest instead of the collection")
+    fetchCmd.Flags().BoolVarP(&force, "force", "f", false, "Replace the local history even if it has revisions the store doesn't")
+    initCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the files that would change without writing anything")
+    requireCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the files that would change without writing anything")
+    pushCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the files that would change without writing anything")
+    syncCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the files that would change without writing anything")
+    syncCmd.Flags().StringVarP(&message, "message", "m", "", "Describe the revisions recorded for pushed changes")
+    initCmd.Flags().StringVar(&symlinkPolicy, "symlinks", "", "Handle symbolic links pointing outside the collection: follow, keep, skip or error")
+    initCmd.Flags().BoolVar(&useGit, "git", false, "Keep the central files in a git repository with a commit per revision")
+    initCmd.Flags().StringVar(&gitRemote, "git-remote", "", "Push the commits of a git-backed collection to this repository, implies --git")
+    addCmd.Flags().BoolVar(&useGit, "git", false, "Keep the central files in a git repository with a commit per revision")
+    addCmd.Flags().StringVar(&gitRemote, "git-remote", "", "Push the commits of a git-backed collection to this repository, implies --git")
+    initCmd.Flags().StringArrayVar(&rewriteRules, "rewrite", nil, "Name the PHP namespace, Go import path or JS module path central files declare, as <php|go|js>=<name>")
+    addCmd.Flags().StringArrayVar(&rewriteRules, "rewrite", nil, "Name the PHP namespace, Go import path or JS module path central files declare, as <php|go|js>=<name>")
+    initCmd.Flags().BoolVar(&provenanceHeader, "header", false, "Start installed files with a comment naming the collection and revision; --header=false turns it off")
+    addCmd.Flags().BoolVar(&provenanceHeader, "header", false, "Start installed files with a comment naming the collection and revision; --header=false turns it off")
+    addCmd.Flags().StringVar(&symlinkPolicy, "symlinks", "", "Handle symbolic links pointing outside the collection: follow, keep, skip or error")
 }
@@ -33,29 +33,113 @@ var pushCmd = &cobra.Command{
         }
 
         for _, collectionName := range collectionsToPush {
+            // Find where the collection is installed in this project
+            projectPath, err := collections.ResolveProjectPath(collectionName, cwd)
+            if err != nil {
+                return err
+            }
+
             // Step 1: Check for changes
-            changeStatus, err := collections.CheckForChanges(collectionName, cwd)
+            changeStatus, err := collections.CheckForChanges(collectionName, projectPath)
             if err != nil {
                 return fmt.Errorf("failed to check changes for collection %s: %w", collectionName, err)
             }
 
             // Step 2: Handle conflicts
-            if len(changeStatus.CentralNewer) > 0 && !force {
-                return fmt.Errorf("conflict detected: central files are newer than local files in collection %s. Use --force to overwrite", collectionName)
+            filesToPush := append([]string{}, changeStatus.LocalNewer...)
+            if prune {
+                filesToPush = append(filesToPush, changeStatus.LocalDeleted...)
+            } else {
+                for _, file := range changeStatus.LocalDeleted {
+                    relPath, _ := filepath.Rel(projectPath, file)
+                    printText("Keeping %s in central collection for %s. Use --prune to delete it\n", relPath, collectionName)
+                }
+            }
+            if dryRun {
+                if force {
+                    filesToPush = append(append([]string{}, filesToPush...), changeStatus.Conflicts...)
+                }
+                plan, err := collections.PlanPush(collectionName, projectPath, filesToPush)
+                if err != nil {
+                    return err
+                }
+                if !force {
+                    // Conflicts are merged when pushing for real
+                    if err := plan.AddConflicts(changeStatus.Conflicts); err != nil {
+                        return err
+                    }
+                }
+                addPlan("push", plan)
+                continue
             }
 
-            // Step 3: Push updates for files that are newer in the local project
-            for _, file := range changeStatus.LocalNewer {
-                relPath, _ := filepath.Rel(cwd, file)
-                centralPath := filepath.Join(collections.GetCollectionPath(collectionName), relPath)
-                if err := collections.CopyFile(file, centralPath); err != nil {
-                    return fmt.Errorf("failed to copy %s to central collection: %w", file, err)
+            var merged []collections.FileResult
+            var unresolved []string
+            if len(changeStatus.Conflicts) > 0 {
+                if !force && settings.Conflict == "abort" {
+                    addResult(collections.Result{Collection: collectionName, Project: projectPath, Action: "push", Conflicts: changeStatus.Conflicts})
+                    return fmt.Errorf("push aborted: files changed both locally and in central for collection %s", collectionName)
+                }
+                if force && !confirm("Overwrite %d central files of %s changed since the last sync?", len(changeStatus.Conflicts), collectionName) {
+                    return fmt.Errorf("push aborted: not confirmed")
                 }
-                fmt.Printf("Updated %s in central collection for %s\n", relPath, collectionName)
+            }
+
+            // Let the pre-push hooks check the project files before anything reaches central
+            if err := runHooks(collections.HookPrePush, collectionName, projectPath, append(append([]string{}, filesToPush...), changeStatus.Conflicts...)); err != nil {
+                return err
+            }
+
+            if len(changeStatus.Conflicts) > 0 {
+                if force {
+                    filesToPush = append(append([]string{}, filesToPush...), changeStatus.Conflicts...)
+                } else {
+                    // Merge files changed on both sides, leaving conflict markers where they can't be merged
+                    merged, unresolved, err = collections.MergeFiles(collectionName, projectPath, changeStatus.Conflicts, true)
+                    if err != nil {
+                        addResult(collections.Result{Collection: collectionName, Project: projectPath, Action: "push", Files: merged, Conflicts: unresolved})
+                        return err
+                    }
+                    for _, file := range merged {
+                        printText("Merged %s in central collection for %s\n", file.Path, collectionName)
+                    }
+                }
+            }
+
+            // Step 3: Push updates for files that were modified in the local project
+            pushed, err := collections.PushFiles(collectionName, projectPath, filesToPush)
+            pushed = append(merged, pushed...)
+            if err != nil {
+                addResult(collections.Result{Collection: collectionName, Project: projectPath, Action: "push", Files: pushed, Conflicts: unresolved})
+                return err
+            }
+            for _, file := range pushed {
+                if file.Action == collections.ActionMerged {
+                    continue
+                } else if file.Action == collections.ActionDeleted {
+                    printText("Deleted %s from central collection for %s\n", file.Path, collectionName)
+                } else {
+                    printText("Updated %s in central collection for %s\n", file.Path, collectionName)
+                }
+            }
+
+            // Step 4: Snapshot the collection so the push can be traced and undone
+            revision, err := recordRevision(collectionName, fmt.Sprintf("Push from %s", projectPath))
+            addResult(collections.Result{Collection: collectionName, Project: projectPath, Action: "push", Files: pushed, Conflicts: unresolved, Revision: revision})
+            if err != nil {
+                return err
+            }
+            if err := runResultHooks(collections.HookPostPush, collectionName, projectPath, pushed); err != nil {
+                return err
+            }
+            if len(unresolved) > 0 {
+                return conflictError(collectionName, unresolved)
             }
         }
 
-        fmt.Println("Push operation completed successfully.")
+        if !dryRun {
+            printText("Push operation completed successfully.\n")
+        }
         return nil
     },
 }
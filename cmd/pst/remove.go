@@ -0,0 +1,56 @@
+// cmd/pst/remove.go
+
+package pst
+
+import (
+	"fmt"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"github.com/spf13/cobra"
+)
+
+var removeCmd = &cobra.Command{
+    Use:   "remove <collection-name> <path>",
+    Short: "Remove a file or folder from a collection",
+    Args:  cobra.ExactArgs(2),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        collectionName := args[0]
+        if err := collections.IsValidCollectionName(collectionName); err != nil {
+            return err
+        }
+
+        if !confirm("Remove %s from collection %s?", args[1], collectionName) {
+            return fmt.Errorf("remove aborted: not confirmed")
+        }
+
+        results, err := collections.RemoveFromCollection(collectionName, args[1], deleteCopies)
+        for _, result := range results {
+            addResult(result)
+        }
+        if err != nil {
+            return fmt.Errorf("failed to remove %s from collection: %w", args[1], err)
+        }
+
+        revision, err := recordRevision(collectionName, fmt.Sprintf("Remove %s", args[1]))
+        if err != nil {
+            return err
+        }
+        if revision != nil {
+            addResult(collections.Result{Collection: collectionName, Action: "remove", Revision: revision})
+        }
+
+        for _, result := range results {
+            for _, file := range result.Files {
+                if result.Project == "" {
+                    printText("Removed %s from collection %s\n", file.Path, collectionName)
+                } else {
+                    printText("Deleted %s from %s\n", file.Path, result.Project)
+                }
+            }
+            for _, file := range result.Conflicts {
+                printText("Kept %s, which has local modifications\n", file)
+            }
+        }
+        return nil
+    },
+}
@@ -4,49 +4,277 @@ package pst
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/forsvunnet/project-sync-tool/internal/collections"
 	"github.com/spf13/cobra"
 )
 
 
 var requireCmd = &cobra.Command{
-    Use:   "require <collection-name> [target-path]",
-    Short: "Require files from a named collection into the target project",
-    Args:  cobra.MinimumNArgs(1),
+    Use:   "require [collection-name[@revision]] [target-path]",
+    Short: "Require files from a named collection, or every collection in the project manifest, into the target project",
+    Args:  cobra.MaximumNArgs(2),
     RunE: func(cmd *cobra.Command, args []string) error {
-        collectionName := args[0]
+        cwd, err := os.Getwd()
+        if err != nil {
+            return fmt.Errorf("failed to get current working directory: %w", err)
+        }
+
+        // Without arguments, restore every collection declared in the project manifest
+        if len(args) == 0 {
+            root, ok := collections.FindProjectRoot(cwd)
+            if !ok {
+                return fmt.Errorf("no collection given and no %s found in %s or its parents", collections.ManifestFileName, cwd)
+            }
+            manifest, err := collections.LoadManifest(root)
+            if err != nil {
+                return err
+            }
+            for _, collectionName := range manifest.Names() {
+                targetPath := filepath.Join(root, manifest.Collections[collectionName].Target)
+                if err := requireCollection(collectionName, "", targetPath); err != nil {
+                    return err
+                }
+            }
+            return nil
+        }
 
-        // Validate the collection name
-        if err := collections.IsValidCollectionName(collectionName); err != nil {
+        // Validate the collection name and split off a requested revision
+        collectionName, revision, err := collections.ParseCollectionRef(args[0])
+        if err != nil {
             return err
         }
 
-        // Determine the target directory
-        targetPath := "."
+        // Determine the target directory, defaulting to where the manifest installs the collection
+        var targetPath string
         if len(args) > 1 {
             targetPath = args[1]
+        } else {
+            targetPath, err = collections.ResolveProjectPath(collectionName, cwd)
+            if err != nil {
+                return err
+            }
         }
 
-        // Step 1: Check for changes to ensure local files aren't newer
-        changeStatus, err := collections.CheckForChanges(collectionName, targetPath)
-        if err != nil {
-            return fmt.Errorf("failed to check changes for collection %s: %w", collectionName, err)
+        // Record where collection paths go in this project before copying anything
+        if len(pathMappings) > 0 {
+            if dryRun {
+                return fmt.Errorf("--dry-run can't be combined with --map, which updates the manifest first")
+            }
+            mappings := map[string]string{}
+            for _, mapping := range pathMappings {
+                from, to, ok := strings.Cut(mapping, "=")
+                if !ok || from == "" || to == "" {
+                    return fmt.Errorf("invalid mapping %q: expected <collection-path>=<project-path>", mapping)
+                }
+                mappings[from] = to
+            }
+            if err := collections.SetMappings(collectionName, targetPath, mappings); err != nil {
+                return err
+            }
         }
 
-        // If there are local files newer than the central ones, fail the command
-		if len(changeStatus.LocalNewer) > 0 && !force {
-            return fmt.Errorf("require aborted: local files are newer than central files for collection %s", collectionName)
+        // Record the values placeholders in central files take in this project
+        if len(templateVariables) > 0 {
+            if dryRun {
+                return fmt.Errorf("--dry-run can't be combined with --var, which updates the manifest first")
+            }
+            variables := map[string]string{}
+            for _, variable := range templateVariables {
+                name, value, ok := strings.Cut(variable, "=")
+                if !ok || name == "" {
+                    return fmt.Errorf("invalid variable %q: expected <name>=<value>", variable)
+                }
+                variables[name] = value
+            }
+            if err := collections.SetVariables(collectionName, targetPath, variables); err != nil {
+                return err
+            }
+        }
+        if len(rewriteRules) > 0 {
+            if dryRun {
+                return fmt.Errorf("--dry-run can't be combined with --rewrite, which updates the manifest first")
+            }
+            rewrites, err := parseRewrites(rewriteRules)
+            if err != nil {
+                return err
+            }
+            if err := collections.SetRewrites(collectionName, targetPath, rewrites); err != nil {
+                return err
+            }
         }
 
-        // Proceed with requiring the collection if all checks pass
-        if err := collections.RequireCollection(collectionName, targetPath); err != nil {
+        return requireCollection(collectionName, revision, targetPath)
+    },
+}
+
+// requireCollection checks a collection for local modifications and copies it into the target path.
+// A non-empty revision installs that revision instead of the current central files.
+func requireCollection(collectionName, revision, targetPath string) error {
+    if dryRun {
+        return planRequire(collectionName, revision, targetPath)
+    }
+
+    // A version constraint pins the project, while plain revisions are installed as is. The pin is
+    // previewed for the checks and written with the files
+    pin, constraint, err := planPin(collectionName, revision, targetPath)
+    if err != nil {
+        return err
+    }
+    if pin != nil {
+        defer pin.Preview()()
+    }
+    if constraint != "" {
+        revision = ""
+    }
+
+    // Step 1: Check for changes to ensure local modifications aren't overwritten
+    changeStatus, err := collections.CheckForChanges(collectionName, targetPath)
+    if err != nil {
+        return fmt.Errorf("failed to check changes for collection %s: %w", collectionName, err)
+    }
+
+    // If there are local files modified since the last sync, fail the command
+    if len(changeStatus.Conflicts) > 0 && !force {
+        addResult(collections.Result{Collection: collectionName, Project: targetPath, Action: "require", Conflicts: changeStatus.Conflicts})
+        return fmt.Errorf("require aborted: files changed both locally and in central for collection %s", collectionName)
+    }
+    if len(changeStatus.LocalNewer) > 0 && !force {
+        return fmt.Errorf("require aborted: local files have been modified for collection %s", collectionName)
+    }
+    if modified := len(changeStatus.Conflicts) + len(changeStatus.LocalNewer); modified > 0 && !confirm("Overwrite %d modified files in %s?", modified, targetPath) {
+        return fmt.Errorf("require aborted: not confirmed")
+    }
+
+    // Proceed with requiring the collection if all checks pass and the pre-require hooks accept the changes
+    var plan *collections.Plan
+    if revision != "" {
+        plan, err = collections.PlanRequireRevision(collectionName, revision, targetPath)
+    } else {
+        plan, err = collections.PlanRequire(collectionName, targetPath)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to require collection: %w", err)
+    }
+    plan.SetPin(pin)
+    // Files deleted from the project aren't restored unless forced, so removed helpers don't reappear
+    if !force {
+        plan.Keep(changeStatus.LocalDeleted)
+        for _, file := range changeStatus.LocalDeleted {
+            printText("Not restoring %s, which was deleted locally. Use push --prune to delete it from central or --force to restore it\n", file)
+        }
+    }
+    // Files that were deleted from central are removed in the same plan, if requested
+    if prune && revision == "" {
+        if err := plan.AddPull(changeStatus.CentralDeleted); err != nil {
             return fmt.Errorf("failed to require collection: %w", err)
         }
+    } else if revision == "" {
+        for _, file := range changeStatus.CentralDeleted {
+            printText("Keeping %s, which was deleted from central. Use --prune to delete it\n", file)
+        }
+    }
+    pending := plan.PendingFiles()
+    if err := runHooks(collections.HookPreRequire, collectionName, targetPath, pending); err != nil {
+        return err
+    }
+    files, err := plan.Apply()
+    if err != nil {
+        addResult(collections.Result{Collection: collectionName, Project: targetPath, Action: "require", Files: files})
+        return fmt.Errorf("failed to require collection: %w", err)
+    }
 
-        fmt.Printf("Collection %s required successfully to %s.\n", collectionName, targetPath)
-        return nil
-    },
+    addResult(collections.Result{Collection: collectionName, Project: targetPath, Action: "require", Files: files})
+    if constraint == "latest" {
+        printText("Collection %s now follows the latest central files\n", collectionName)
+    } else if constraint != "" {
+        printText("Pinned collection %s to %s, selecting %s\n", collectionName, constraint, pin.Tag)
+    }
+
+    // Step 2: Note the commit installed from a git-backed collection
+    if err := collections.RecordCommit(collectionName, targetPath, revision); err != nil {
+        return fmt.Errorf("failed to record commit for collection %s: %w", collectionName, err)
+    }
+    if err := runResultHooks(collections.HookPostRequire, collectionName, targetPath, files); err != nil {
+        return err
+    }
+
+    printText("Collection %s required successfully to %s.\n", collectionName, targetPath)
+    return nil
+}
+
+// parseRewrites parses rewrite rules given as <language>=<name>.
+func parseRewrites(rules []string) (map[string]string, error) {
+    rewrites := map[string]string{}
+    for _, rule := range rules {
+        language, name, ok := strings.Cut(rule, "=")
+        if !ok || language == "" || name == "" {
+            return nil, fmt.Errorf("invalid rewrite rule %q: expected <language>=<name>", rule)
+        }
+        rewrites[language] = name
+    }
+    return rewrites, nil
+}
+
+// planPin resolves the pin a require sets: the given version constraint, or the project's current constraint
+// when no revision is given. It also returns the constraint given, which is empty for plain revisions.
+func planPin(collectionName, revision, targetPath string) (*collections.Pin, string, error) {
+    if collections.IsVersionConstraint(revision) {
+        pin, err := collections.PlanPin(collectionName, targetPath, revision)
+        return pin, revision, err
+    }
+    if revision == "" {
+        pin, err := collections.PlanPin(collectionName, targetPath, "")
+        return pin, "", err
+    }
+    return nil, "", nil
 }
 
+// planRequire reports the files requireCollection would change without writing anything.
+func planRequire(collectionName, revision, targetPath string) error {
+    pin, constraint, err := planPin(collectionName, revision, targetPath)
+    if err != nil {
+        return err
+    }
+    if revision != "" && constraint == "" {
+        return fmt.Errorf("--dry-run can't be combined with a revision")
+    }
+    if pin != nil {
+        defer pin.Preview()()
+    }
+
+    changeStatus, err := collections.CheckForChanges(collectionName, targetPath)
+    if err != nil {
+        return fmt.Errorf("failed to check changes for collection %s: %w", collectionName, err)
+    }
 
+    // Report the files that would abort the require
+    if !force && (len(changeStatus.Conflicts) > 0 || len(changeStatus.LocalNewer) > 0) {
+        plan := &collections.Plan{Collection: collectionName, Project: targetPath}
+        if err := plan.AddConflicts(append(append([]string{}, changeStatus.Conflicts...), changeStatus.LocalNewer...)); err != nil {
+            return err
+        }
+        addPlan("require", plan)
+        return fmt.Errorf("require aborted: local files have been modified for collection %s", collectionName)
+    }
 
+    plan, err := collections.PlanRequire(collectionName, targetPath)
+    if err != nil {
+        return err
+    }
+    if !force {
+        plan.Keep(changeStatus.LocalDeleted)
+    }
+    if prune {
+        pull, err := collections.PlanPull(collectionName, targetPath, changeStatus.CentralDeleted)
+        if err != nil {
+            return err
+        }
+        plan.Files = append(plan.Files, pull.Files...)
+    }
+    addPlan("require", plan)
+    return nil
+}
@@ -0,0 +1,62 @@
+// cmd/pst/resolve.go
+
+package pst
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"github.com/spf13/cobra"
+)
+
+var resolveCmd = &cobra.Command{
+    Use:   "resolve <collection-name> [path...]",
+    Short: "Mark merge conflicts as resolved so the files can be pushed",
+    Args:  cobra.MinimumNArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        collectionName := args[0]
+        if err := collections.IsValidCollectionName(collectionName); err != nil {
+            return err
+        }
+
+        cwd, err := os.Getwd()
+        if err != nil {
+            return fmt.Errorf("failed to get current working directory: %w", err)
+        }
+        projectPath, err := collections.ResolveProjectPath(collectionName, cwd)
+        if err != nil {
+            return err
+        }
+
+        // Paths are given relative to the current directory
+        files := []string{}
+        for _, path := range args[1:] {
+            if !filepath.IsAbs(path) {
+                path = filepath.Join(cwd, path)
+            }
+            files = append(files, path)
+        }
+
+        resolved, err := collections.ResolveFiles(collectionName, projectPath, files, force)
+        addResult(collections.Result{Collection: collectionName, Project: projectPath, Action: "resolve", Files: resolved})
+        if err != nil {
+            return err
+        }
+
+        if len(resolved) == 0 {
+            printText("No unresolved conflicts for collection %s.\n", collectionName)
+        }
+        for _, file := range resolved {
+            printText("Resolved %s, push it to update collection %s\n", file.Path, collectionName)
+        }
+        return nil
+    },
+}
+
+// conflictError reports files left with merge conflicts.
+func conflictError(collectionName string, files []string) error {
+    return fmt.Errorf("merge conflicts in %s for collection %s. Fix the conflict markers and run pst resolve %s", strings.Join(files, ", "), collectionName, collectionName)
+}
@@ -0,0 +1,43 @@
+// cmd/pst/show.go
+
+package pst
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"github.com/spf13/cobra"
+)
+
+var showCmd = &cobra.Command{
+    Use:   "show <collection-name> [revision]",
+    Short: "Show a revision of a collection and the changes it made, defaulting to the latest revision",
+    Args:  cobra.RangeArgs(1, 2),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        collectionName := args[0]
+        if err := collections.IsValidCollectionName(collectionName); err != nil {
+            return err
+        }
+
+        ref := ""
+        if len(args) > 1 {
+            ref = args[1]
+        }
+        revision, patch, err := collections.ShowRevision(collectionName, ref)
+        if err != nil {
+            return fmt.Errorf("failed to show revision of %s: %w", collectionName, err)
+        }
+        addResult(collections.Result{Collection: collectionName, Action: "show", Files: revision.Changes, Revision: summarizeRevision(revision)})
+
+        printText("revision %s\n", revision.ShortID())
+        if revision.Commit != "" {
+            printText("Commit: %s\n", revision.Commit)
+        }
+        printText("Author: %s\n", revision.Author)
+        printText("Date:   %s\n\n", revision.Time.Local().Format("2006-01-02 15:04:05 -0700"))
+        printText("    %s\n\n", revision.Message)
+        printText("%s", colorizeDiff(patch, isTerminal(os.Stdout)))
+        return nil
+    },
+}
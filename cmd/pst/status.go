@@ -0,0 +1,103 @@
+// cmd/pst/status.go
+
+package pst
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+    Use:          "status [collection-name...]",
+    Short:        "Show tracked files and their sync state for each collection",
+    SilenceUsage: true,
+    RunE: func(cmd *cobra.Command, args []string) error {
+        cwd, err := os.Getwd()
+        if err != nil {
+            return fmt.Errorf("failed to get current working directory: %w", err)
+        }
+
+        // Determine collections to report on
+        var collectionsToCheck []string
+        if len(args) > 0 {
+            collectionsToCheck = args
+        } else {
+            collectionsToCheck, err = collections.ScanForCollections(cwd)
+            if err != nil {
+                return fmt.Errorf("failed to scan for collections: %w", err)
+            }
+        }
+
+        inSync := true
+        for _, collectionName := range collectionsToCheck {
+            projectPath, err := collections.ResolveProjectPath(collectionName, cwd)
+            if err != nil {
+                return err
+            }
+
+            changeStatus, err := collections.CheckForChanges(collectionName, projectPath)
+            if err != nil {
+                return fmt.Errorf("failed to check changes for collection %s: %w", collectionName, err)
+            }
+            if !changeStatus.InSync() {
+                inSync = false
+            }
+
+            if !filesOnly {
+                printText("%s:\n", collectionName)
+            }
+            result := collections.Result{Collection: collectionName, Project: projectPath, Action: "status"}
+            states := []struct {
+                action string
+                label  string
+                files  []string
+            }{
+                {"in-sync", "in sync", changeStatus.Unchanged},
+                {"local-ahead", "local ahead", changeStatus.LocalNewer},
+                {"deleted-locally", "deleted locally", changeStatus.LocalDeleted},
+                {"central-ahead", "central ahead", changeStatus.CentralNewer},
+                {"missing-locally", "missing locally", changeStatus.MissingLocal},
+                {"missing-centrally", "missing centrally", changeStatus.CentralDeleted},
+                {"conflicting", "conflicting", changeStatus.Conflicts},
+            }
+            for _, state := range states {
+                for _, file := range state.files {
+                    relPath, _ := filepath.Rel(cwd, file)
+                    result.Files = append(result.Files, collections.FileResult{Path: relPath, Action: state.action})
+                    if filesOnly {
+                        printText("%s\n", relPath)
+                    } else {
+                        printText("  %-18s %s\n", state.label, relPath)
+                    }
+                }
+            }
+            result.Conflicts = changeStatus.Conflicts
+
+            // Report newer versions for projects pinned to a version
+            result.Versions, err = collections.CheckVersions(collectionName, projectPath)
+            if err != nil {
+                return err
+            }
+            if versions := result.Versions; versions != nil && !filesOnly {
+                printText("  pinned to %s at %s\n", versions.Constraint, versions.Current)
+                if versions.Compatible != "" {
+                    printText("  newer compatible version %s available, run pst sync to update\n", versions.Compatible)
+                }
+                if versions.Incompatible != "" {
+                    printText("  newer incompatible version %s available\n", versions.Incompatible)
+                }
+            }
+            addResult(result)
+        }
+
+        if !inSync {
+            return errors.New("collections are out of sync")
+        }
+        return nil
+    },
+}
@@ -0,0 +1,115 @@
+// cmd/pst/store.go
+
+package pst
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"github.com/forsvunnet/project-sync-tool/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var storeCmd = &cobra.Command{
+    Use:   "store",
+    Short: "Manage named stores that collections can be qualified with, as in <store>/<collection>",
+}
+
+var storeAddCmd = &cobra.Command{
+    Use:   "add <name> <path>",
+    Short: "Add a named store kept in a directory, such as a shared mount",
+    Args:  cobra.ExactArgs(2),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        if err := config.AddStore(args[0], args[1]); err != nil {
+            return fmt.Errorf("failed to add store %s: %w", args[0], err)
+        }
+        printText("Added store %s.\n", args[0])
+        return nil
+    },
+}
+
+var storeRemoveCmd = &cobra.Command{
+    Use:   "remove <name>",
+    Short: "Forget a named store, leaving its files in place",
+    Args:  cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        if err := config.RemoveStore(args[0]); err != nil {
+            return fmt.Errorf("failed to remove store %s: %w", args[0], err)
+        }
+        printText("Removed store %s.\n", args[0])
+        return nil
+    },
+}
+
+var storeListCmd = &cobra.Command{
+    Use:   "list",
+    Short: "List the default store and every named store",
+    Args:  cobra.NoArgs,
+    RunE: func(cmd *cobra.Command, args []string) error {
+        roots := config.StoreRoots()
+        names := []string{}
+        for name := range roots {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+
+        for _, name := range names {
+            label := name
+            if label == "" {
+                label = "(default)"
+            }
+            addSetting(label, roots[name])
+            printText("%-15s %s\n", label, roots[name])
+        }
+        return nil
+    },
+}
+
+var publishCmd = &cobra.Command{
+    Use:   "publish <collection-name> <store>",
+    Short: "Copy a collection and its history to a named store",
+    Args:  cobra.ExactArgs(2),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        _, name, _ := strings.Cut(args[0], "/")
+        if name == "" {
+            name = args[0]
+        }
+        return transferCollection("publish", args[0], args[1]+"/"+name)
+    },
+}
+
+var fetchCmd = &cobra.Command{
+    Use:   "fetch <store>/<collection-name>",
+    Short: "Copy a collection and its history from a named store to the default store",
+    Args:  cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        _, name, found := strings.Cut(args[0], "/")
+        if !found {
+            return fmt.Errorf("fetch needs a collection qualified by its store, as in team/%s", args[0])
+        }
+        return transferCollection("fetch", args[0], name)
+    },
+}
+
+// transferCollection copies a collection between stores and reports the central files that changed.
+func transferCollection(action, source, target string) error {
+    for _, name := range []string{source, target} {
+        if err := collections.IsValidCollectionName(name); err != nil {
+            return err
+        }
+    }
+
+    files, err := collections.TransferCollection(source, target, force)
+    addResult(collections.Result{Collection: target, Action: action, Files: files})
+    if err != nil {
+        return fmt.Errorf("failed to %s %s: %w", action, source, err)
+    }
+
+    for _, file := range files {
+        printText("%s %s\n", file.Action, file.Path)
+    }
+    printText("Copied %s to %s.\n", source, target)
+    return nil
+}
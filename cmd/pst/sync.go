@@ -0,0 +1,238 @@
+// cmd/pst/sync.go
+
+package pst
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+    Use:   "sync [collection-name...]",
+    Short: "Sync collections in the current directory or globally",
+    RunE: func(cmd *cobra.Command, args []string) error {
+        // Determine the projects to sync
+        var projects []string
+        if global {
+            var err error
+            projects, err = collections.ListProjectPaths()
+            if err != nil {
+                return fmt.Errorf("failed to list projects: %w", err)
+            }
+        } else {
+            cwd, err := os.Getwd()
+            if err != nil {
+                return fmt.Errorf("failed to get current working directory: %w", err)
+            }
+            projects = []string{cwd}
+        }
+
+        // Step 1: Check every collection in every project before touching any files
+        targets := []collections.SyncTarget{}
+        seen := map[string]bool{}
+        for _, project := range projects {
+            projectCollections, err := collections.ScanForCollections(project)
+            if err != nil {
+                return fmt.Errorf("failed to scan for collections: %w", err)
+            }
+
+            for _, collectionName := range projectCollections {
+                if len(args) > 0 && !slices.Contains(args, collectionName) {
+                    continue
+                }
+
+                // Find where the collection is installed, skipping pairs already reached from another path
+                projectPath, err := collections.ResolveProjectPath(collectionName, project)
+                if err != nil {
+                    return err
+                }
+                if seen[collectionName+"\x00"+projectPath] {
+                    continue
+                }
+                seen[collectionName+"\x00"+projectPath] = true
+
+                // Pinned projects move to the highest version their constraint allows, which is previewed
+                // for the checks and written once nothing aborts the run
+                version := ""
+                pin, err := collections.PlanPin(collectionName, projectPath, "")
+                if err != nil {
+                    return err
+                }
+                if pin != nil {
+                    defer pin.Preview()()
+                    version = pin.Tag
+                }
+
+                changeStatus, err := collections.CheckForChanges(collectionName, projectPath)
+                if err != nil {
+                    return fmt.Errorf("failed to check changes for collection %s in %s: %w", collectionName, projectPath, err)
+                }
+
+                targets = append(targets, collections.SyncTarget{Collection: collectionName, Project: projectPath, Status: changeStatus, Version: version})
+            }
+        }
+
+        if dryRun {
+            plans, err := collections.PlanSync(targets, update, prune)
+            if err != nil {
+                return err
+            }
+            for _, plan := range plans {
+                addPlan("sync", plan)
+            }
+            return nil
+        }
+
+        // Step 2: Abort the whole run if the same file changed on both sides, unless asked to merge such files
+        conflicts, err := collections.FindSyncConflicts(targets, update, prune)
+        if err != nil {
+            return fmt.Errorf("failed to check for conflicts: %w", err)
+        }
+        if len(conflicts) > 0 && mergeConflicts {
+            // Clean merges become local changes of the project, conflicting ones get conflict markers
+            for i, target := range targets {
+                if len(target.Status.Conflicts) == 0 {
+                    continue
+                }
+                merged, _, err := collections.MergeFiles(target.Collection, target.Project, target.Status.Conflicts, false)
+                if err != nil {
+                    return err
+                }
+                for _, file := range merged {
+                    printText("Merged central changes to %s into %s\n", file.Path, target.Project)
+                }
+                targets[i].Status, err = collections.CheckForChanges(target.Collection, target.Project)
+                if err != nil {
+                    return fmt.Errorf("failed to check changes for collection %s in %s: %w", target.Collection, target.Project, err)
+                }
+            }
+            if conflicts, err = collections.FindSyncConflicts(targets, update, prune); err != nil {
+                return fmt.Errorf("failed to check for conflicts: %w", err)
+            }
+        }
+        if len(conflicts) > 0 {
+            for _, target := range targets {
+                if len(target.Status.Conflicts) > 0 {
+                    addResult(collections.Result{Collection: target.Collection, Project: target.Project, Action: "sync", Conflicts: target.Status.Conflicts})
+                }
+            }
+            if !mergeConflicts {
+                return fmt.Errorf("sync aborted: files changed on both sides: %s. Use --merge to merge them", strings.Join(conflicts, ", "))
+            }
+            return fmt.Errorf("sync aborted: files changed on both sides: %s. Fix the conflict markers and run pst resolve", strings.Join(conflicts, ", "))
+        }
+
+        // Let the pre-sync hooks check the files each project is about to push or pull
+        for _, target := range targets {
+            pending := append(append([]string{}, target.Status.CentralNewer...), target.Status.MissingLocal...)
+            if prune {
+                pending = append(pending, target.Status.CentralDeleted...)
+            }
+            if update && target.Version == "" {
+                pending = append(pending, target.Status.LocalNewer...)
+                if prune {
+                    pending = append(pending, target.Status.LocalDeleted...)
+                }
+            }
+            if err := runHooks(collections.HookPreSync, target.Collection, target.Project, pending); err != nil {
+                return err
+            }
+        }
+
+        for _, target := range targets {
+            if target.Version == "" {
+                continue
+            }
+            if _, err := collections.UpdatePin(target.Collection, target.Project); err != nil {
+                return err
+            }
+        }
+
+        // Step 3: Push local changes first so they reach the other projects in the same run
+        results := make([]collections.Result, len(targets))
+        for i, target := range targets {
+            results[i] = collections.Result{Collection: target.Collection, Project: target.Project, Action: "sync"}
+        }
+        defer func() {
+            for _, result := range results {
+                addResult(result)
+            }
+        }()
+
+        if update {
+            for i, target := range targets {
+                if target.Version != "" {
+                    if len(target.Status.LocalNewer) > 0 {
+                        printText("Not pushing changes to %s from %s, which is pinned to %s\n", target.Collection, target.Project, target.Version)
+                    }
+                    continue
+                }
+                filesToPush := append([]string{}, target.Status.LocalNewer...)
+                if prune {
+                    filesToPush = append(filesToPush, target.Status.LocalDeleted...)
+                }
+                pushed, err := collections.PushFiles(target.Collection, target.Project, filesToPush)
+                results[i].Files = append(results[i].Files, pushed...)
+                if err != nil {
+                    return err
+                }
+                for _, file := range pushed {
+                    if file.Action == collections.ActionDeleted {
+                        printText("Deleted %s from central collection for %s\n", file.Path, target.Collection)
+                    } else {
+                        printText("Updated %s in central collection for %s\n", file.Path, target.Collection)
+                    }
+                }
+                if len(pushed) > 0 {
+                    results[i].Revision, err = recordRevision(target.Collection, fmt.Sprintf("Sync from %s", target.Project))
+                    if err != nil {
+                        return err
+                    }
+                }
+            }
+        }
+
+        // Step 4: Pull files that are newer or new in central, and deletions if requested
+        for i, target := range targets {
+            changeStatus := target.Status
+            if update {
+                // Central may have been updated by the push step, so check again
+                changeStatus, err = collections.CheckForChanges(target.Collection, target.Project)
+                if err != nil {
+                    return fmt.Errorf("failed to check changes for collection %s in %s: %w", target.Collection, target.Project, err)
+                }
+            }
+
+            filesToPull := append(append([]string{}, changeStatus.CentralNewer...), changeStatus.MissingLocal...)
+            if prune {
+                filesToPull = append(filesToPull, changeStatus.CentralDeleted...)
+            }
+            pulled, err := collections.PullFiles(target.Collection, target.Project, filesToPull)
+            results[i].Files = append(results[i].Files, pulled...)
+            if err != nil {
+                return err
+            }
+            for _, file := range pulled {
+                if file.Action == collections.ActionDeleted {
+                    printText("Deleted %s from %s, as in collection %s\n", file.Path, target.Project, target.Collection)
+                } else {
+                    printText("Updated %s in %s from collection %s\n", file.Path, target.Project, target.Collection)
+                }
+            }
+        }
+
+        for i, target := range targets {
+            if err := runResultHooks(collections.HookPostSync, target.Collection, target.Project, results[i].Files); err != nil {
+                return err
+            }
+        }
+
+        printText("Sync operation completed successfully.\n")
+        return nil
+    },
+}
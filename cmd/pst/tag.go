@@ -0,0 +1,36 @@
+// cmd/pst/tag.go
+
+package pst
+
+import (
+	"fmt"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"github.com/spf13/cobra"
+)
+
+var tagCmd = &cobra.Command{
+    Use:   "tag <collection-name> <version> [revision]",
+    Short: "Label a revision of a collection with a semantic version, defaulting to the latest revision",
+    Args:  cobra.RangeArgs(2, 3),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        collectionName, tag := args[0], args[1]
+        if err := collections.IsValidCollectionName(collectionName); err != nil {
+            return err
+        }
+
+        revisionID := ""
+        if len(args) > 2 {
+            revisionID = args[2]
+        }
+
+        revision, err := collections.TagRevision(collectionName, tag, revisionID, force)
+        if err != nil {
+            return fmt.Errorf("failed to tag collection %s: %w", collectionName, err)
+        }
+
+        addResult(collections.Result{Collection: collectionName, Action: "tag", Revision: summarizeRevision(revision)})
+        printText("Tagged revision %s of collection %s as %s.\n", revision.ShortID(), collectionName, tag)
+        return nil
+    },
+}
@@ -0,0 +1,70 @@
+// cmd/pst/undo.go
+
+package pst
+
+import (
+	"fmt"
+
+	"github.com/forsvunnet/project-sync-tool/internal/collections"
+	"github.com/spf13/cobra"
+)
+
+var listOperations bool
+
+var undoCmd = &cobra.Command{
+    Use:   "undo [operation-id]",
+    Short: "Restore the files changed by the last operation, or by the given one from --list",
+    Args:  cobra.MaximumNArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        if listOperations {
+            return listJournal()
+        }
+
+        id, target := "", "the last operation"
+        if len(args) > 0 {
+            id, target = args[0], "operation "+args[0]
+        }
+        if !confirm("Restore the files changed by %s?", target) {
+            return fmt.Errorf("undo aborted: not confirmed")
+        }
+        operation, files, err := collections.UndoOperation(id, force)
+        addResult(collections.Result{Action: "undo", Files: files, Operation: summarizeOperation(&operation)})
+        if err != nil {
+            return fmt.Errorf("failed to undo: %w", err)
+        }
+
+        for _, file := range files {
+            printText("%-9s %s\n", file.Action, file.Path)
+        }
+        printText("Undid operation %s: %s\n", operation.ID, operation.Command)
+        return nil
+    },
+}
+
+// listJournal prints the journaled operations, newest first.
+func listJournal() error {
+    operations, err := collections.GetOperations()
+    if err != nil {
+        return err
+    }
+    for _, operation := range operations {
+        addResult(collections.Result{Action: "undo", Operation: summarizeOperation(&operation)})
+
+        state := ""
+        if operation.Undone != nil {
+            state = " (undone)"
+        }
+        printText("%s  %s  %d files  %s%s\n", operation.ID, operation.Time.Local().Format("2006-01-02 15:04:05"), len(operation.Files), operation.Command, state)
+    }
+    return nil
+}
+
+// summarizeOperation drops the file states from an operation for output.
+func summarizeOperation(operation *collections.Operation) *collections.Operation {
+    if operation.ID == "" {
+        return nil
+    }
+    summary := *operation
+    summary.Files = nil
+    return &summary
+}
@@ -4,8 +4,9 @@ go 1.23.1
 
 require github.com/spf13/cobra v1.8.1 // direct
 
+require gopkg.in/yaml.v3 v3.0.1
+
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
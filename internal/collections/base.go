@@ -0,0 +1,221 @@
+// internal/collections/base.go
+
+package collections
+
+import (
+    "fmt"
+    "maps"
+    "os"
+    "path/filepath"
+    "sort"
+)
+
+// FileState is the recorded state of a file as of the last successful require or push.
+type FileState struct {
+    Checksum string      `yaml:"checksum"`
+    Mode     os.FileMode `yaml:"mode,omitempty"` // Permission bits, or os.ModeSymlink for symbolic links
+}
+
+// fileMode returns the part of a file's mode that is synced: its permission bits, or os.ModeSymlink for links.
+func fileMode(info os.FileInfo) os.FileMode {
+    if info.Mode()&os.ModeSymlink != 0 {
+        return os.ModeSymlink
+    }
+    return info.Mode().Perm()
+}
+
+// fileState returns the checksum and mode of a file, or an empty state if it doesn't exist.
+func fileState(path string) (FileState, error) {
+    info, err := os.Lstat(path)
+    if os.IsNotExist(err) {
+        return FileState{}, nil
+    } else if err != nil {
+        return FileState{}, err
+    }
+
+    checksum, err := calculateChecksum(path)
+    if err != nil {
+        return FileState{}, err
+    }
+    return FileState{Checksum: checksum, Mode: fileMode(info)}, nil
+}
+
+// sameAs reports whether two states have the same content and mode.
+// Modes are ignored when either state has none, as in base snapshots recorded by older versions.
+func (s FileState) sameAs(other FileState) bool {
+    return s.Checksum == other.Checksum && (s.Mode == 0 || other.Mode == 0 || s.Mode == other.Mode)
+}
+
+// loadBase returns the base snapshot recorded for a project, keyed by path relative to the collection.
+// The snapshot is read from the project manifest, falling back to the central metadata used by older versions.
+func loadBase(collectionName, projectPath string) (map[string]FileState, error) {
+    projectPath, err := filepath.Abs(projectPath)
+    if err != nil {
+        return nil, fmt.Errorf("could not determine project path: %w", err)
+    }
+
+    entry, err := findManifestEntry(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    if entry != nil {
+        if entry.Files == nil {
+            return map[string]FileState{}, nil
+        }
+        return entry.Files, nil
+    }
+
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    base := meta.Bases[projectPath]
+    if base == nil {
+        base = map[string]FileState{}
+    }
+    return base, nil
+}
+
+// storeBase writes the base snapshot for a project to its manifest, adding the collection if needed.
+func storeBase(collectionName, projectPath string, base map[string]FileState) error {
+    return updateManifestEntry(collectionName, projectPath, func(entry *ManifestEntry) {
+        entry.Files = base
+    })
+}
+
+// RecordBase stores the base snapshot for a project after a successful require or push.
+func RecordBase(collectionName, projectPath string) error {
+    projectPath, err := filepath.Abs(projectPath)
+    if err != nil {
+        return fmt.Errorf("could not determine project path: %w", err)
+    }
+
+    previous, err := loadBase(collectionName, projectPath)
+    if err != nil {
+        return err
+    }
+    base, err := updateBase(previous, collectionName, projectPath)
+    if err != nil {
+        return err
+    }
+
+    // Projects whose state is already recorded are left alone when nothing changed, so syncing
+    // projects synced by older versions doesn't add a manifest to them
+    if maps.Equal(previous, base) {
+        entry, err := findManifestEntry(collectionName, projectPath)
+        if err != nil || entry != nil {
+            return err
+        }
+        meta, err := requireCollectionMeta(collectionName)
+        if err != nil {
+            return fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+        }
+        if _, ok := meta.Bases[projectPath]; ok {
+            return nil
+        }
+    }
+    return storeBase(collectionName, projectPath, base)
+}
+
+// updateBase records the checksum of every file that is identical in the project and central.
+// Files that still differ, including pending deletions, keep their previous base so the changes remain detectable.
+func updateBase(previous map[string]FileState, collectionName, projectPath string) (map[string]FileState, error) {
+    pairs, err := getTrackedPairs(collectionName, projectPath, previous)
+    if err != nil {
+        return nil, err
+    }
+
+    base := map[string]FileState{}
+    for _, pair := range pairs {
+        central, err := pair.centralState()
+        if err != nil {
+            return nil, fmt.Errorf("failed to calculate checksum for central file %s: %w", pair.CentralPath, err)
+        }
+        project, err := pair.projectState()
+        if err != nil {
+            return nil, fmt.Errorf("failed to calculate checksum for project file %s: %w", pair.ProjectPath, err)
+        }
+
+        if central == project {
+            // Identical files are in sync, and files deleted on both sides are no longer tracked.
+            // The content is kept in the object store as the ancestor for later merges
+            if central.Checksum != "" {
+                if err := pair.writeCentralBlob(collectionName, central.Checksum); err != nil {
+                    return nil, err
+                }
+                base[pair.RelPath] = central
+            }
+        } else if state, ok := previous[pair.RelPath]; ok {
+            base[pair.RelPath] = state
+        }
+    }
+
+    return base, nil
+}
+
+// getTrackedPairs returns the pairs of files in central, extended with the files only known from the base,
+// which have been deleted from central since the last sync.
+func getTrackedPairs(collectionName, projectPath string, base map[string]FileState) ([]FilePair, error) {
+    pairs, err := GetFilePairs(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    mappings, err := loadMappings(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    ignore, err := loadIgnoreRules(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    pinned, err := pinnedRevision(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    filters, err := loadFilters(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    header, err := loadHeader(collectionName, pinned)
+    if err != nil {
+        return nil, err
+    }
+
+    seen := map[string]bool{}
+    for _, pair := range pairs {
+        seen[pair.RelPath] = true
+    }
+
+    missing := []string{}
+    for relPath := range base {
+        if !seen[relPath] {
+            missing = append(missing, relPath)
+        }
+    }
+    sort.Strings(missing)
+
+    tracked := append([]FilePair{}, pairs...)
+    for _, relPath := range missing {
+        pair := newFilePair(collectionName, projectPath, relPath, mappings)
+        pair.filters, pair.header = filters, header
+        if pinned != nil {
+            // Files left out of the pinned revision have no central copy, whatever the latest central files hold
+            pair.CentralPath = ""
+            pair.centralMode = 0
+        }
+        if !ignore.Ignored(pair.RelPath, pair.ProjectPath, false) {
+            tracked = append(tracked, pair)
+        }
+    }
+    return tracked, nil
+}
+
+// fileChecksum returns the checksum of a file, or an empty string if it doesn't exist.
+func fileChecksum(path string) (string, error) {
+    if _, err := os.Lstat(path); os.IsNotExist(err) {
+        return "", nil
+    } else if err != nil {
+        return "", err
+    }
+    return calculateChecksum(path)
+}
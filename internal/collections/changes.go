@@ -3,71 +3,95 @@
 package collections
 
 import (
-    "os"
-    "path/filepath"
     "fmt"
 )
 
 // ChangeStatus represents the comparison result between local and central files.
 type ChangeStatus struct {
-    LocalNewer   []string // Files in the project that are newer than those in central
-    CentralNewer []string // Files in central that are newer than those in the project
+    LocalNewer     []string // Files modified in the project since the last sync
+    LocalDeleted   []string // Files deleted from the project since the last sync
+    CentralNewer   []string // Files modified in central since the last sync
+    CentralDeleted []string // Files deleted from central since the last sync
+    Conflicts      []string // Files changed or deleted on both sides, or differing without a recorded base
+    Unchanged      []string // Files identical in the project and central
+    MissingLocal   []string // Files in central that have never been synced to the project
+}
+
+// InSync reports whether the project and central have no differences.
+func (s ChangeStatus) InSync() bool {
+    return len(s.LocalNewer) == 0 && len(s.LocalDeleted) == 0 && len(s.CentralNewer) == 0 &&
+        len(s.CentralDeleted) == 0 && len(s.Conflicts) == 0 && len(s.MissingLocal) == 0
 }
 
 // CheckForChanges compares files in the local directory and central collection.
-// First checks file checksums; if they match, no further checks are needed.
+// First checks file checksums and modes; if they match, no further checks are needed.
+// Otherwise both sides are compared against the base snapshot recorded at the last sync,
+// which also reveals files deleted on either side.
 func CheckForChanges(collectionName, projectPath string) (ChangeStatus, error) {
-    collectionFiles, err := GetCollectionFiles(collectionName)
+    base, err := loadBase(collectionName, projectPath)
     if err != nil {
         return ChangeStatus{}, err
     }
 
-    status := ChangeStatus{}
-    for _, centralFilePath := range collectionFiles {
-        relPath, err := filepath.Rel(GetCollectionPath(collectionName), centralFilePath)
-        if err != nil {
-            return ChangeStatus{}, fmt.Errorf("failed to calculate relative path: %w", err)
-        }
-
-        projectFilePath := filepath.Join(projectPath, relPath)
+    pairs, err := getTrackedPairs(collectionName, projectPath, base)
+    if err != nil {
+        return ChangeStatus{}, err
+    }
 
-        // If the project file doesn’t exist, mark it as a new local file
-        projectInfo, err := os.Stat(projectFilePath)
-        if os.IsNotExist(err) {
-            continue
-        } else if err != nil {
-            return ChangeStatus{}, fmt.Errorf("failed to stat project file %s: %w", projectFilePath, err)
-        }
+    status := ChangeStatus{}
+    for _, pair := range pairs {
+        projectFilePath := pair.ProjectPath
 
-        // Calculate checksums for both files
-        centralChecksum, err := calculateChecksum(centralFilePath)
+        // Calculate checksums and modes for both files, empty if the file doesn't exist
+        central, err := pair.centralState()
         if err != nil {
-            return ChangeStatus{}, fmt.Errorf("failed to calculate checksum for central file %s: %w", centralFilePath, err)
+            return ChangeStatus{}, fmt.Errorf("failed to calculate checksum for central file %s: %w", pair.CentralPath, err)
         }
-
-        projectChecksum, err := calculateChecksum(projectFilePath)
+        project, err := pair.projectState()
         if err != nil {
             return ChangeStatus{}, fmt.Errorf("failed to calculate checksum for project file %s: %w", projectFilePath, err)
         }
+        centralChecksum, projectChecksum := central.Checksum, project.Checksum
 
-        // If checksums match, skip further checks for this file
-        if centralChecksum == projectChecksum {
+        // If content and mode match, skip further checks for this file
+        if central == project {
+            if centralChecksum != "" {
+                status.Unchanged = append(status.Unchanged, projectFilePath)
+            }
             continue
         }
 
-        // If checksums differ, compare timestamps to determine which is newer
-        centralInfo, err := os.Stat(centralFilePath)
-        if err != nil {
-            return ChangeStatus{}, fmt.Errorf("failed to stat central file %s: %w", centralFilePath, err)
+        // Without a recorded base the direction can't be determined, so treat differing files as a conflict
+        state, ok := base[pair.RelPath]
+        if !ok {
+            if projectChecksum == "" {
+                status.MissingLocal = append(status.MissingLocal, projectFilePath)
+            } else {
+                status.Conflicts = append(status.Conflicts, projectFilePath)
+            }
+            continue
         }
 
-        if projectInfo.ModTime().After(centralInfo.ModTime()) {
+        // Compare each side against the base to determine which changed, including changes of mode
+        localChanged := !project.sameAs(state)
+        centralChanged := !central.sameAs(state)
+
+        switch {
+        case localChanged && centralChanged:
+            status.Conflicts = append(status.Conflicts, projectFilePath)
+        case localChanged && projectChecksum == "":
+            status.LocalDeleted = append(status.LocalDeleted, projectFilePath)
+        case localChanged:
             status.LocalNewer = append(status.LocalNewer, projectFilePath)
-        } else if centralInfo.ModTime().After(projectInfo.ModTime()) {
+        case centralChanged && centralChecksum == "":
+            status.CentralDeleted = append(status.CentralDeleted, projectFilePath)
+        case centralChanged:
             status.CentralNewer = append(status.CentralNewer, projectFilePath)
+        default:
+            // Only the modes differ, and the base has no mode to tell which side changed
+            status.Conflicts = append(status.Conflicts, projectFilePath)
         }
     }
 
     return status, nil
 }
-
@@ -10,7 +10,16 @@ import (
 )
 
 // calculateChecksum calculates the SHA-256 checksum of a file at the given path.
+// Symbolic links are not followed: the checksum of a link is that of the path it points to.
 func calculateChecksum(filePath string) (string, error) {
+    if info, err := os.Lstat(filePath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+        target, err := os.Readlink(filePath)
+        if err != nil {
+            return "", fmt.Errorf("failed to read link for checksum calculation: %w", err)
+        }
+        return fmt.Sprintf("%x", sha256.Sum256([]byte(target))), nil
+    }
+
     file, err := os.Open(filePath)
     if err != nil {
         return "", fmt.Errorf("failed to open file for checksum calculation: %w", err)
@@ -25,3 +34,8 @@ func calculateChecksum(filePath string) (string, error) {
     return fmt.Sprintf("%x", hasher.Sum(nil)), nil
 }
 
+
+// checksumData calculates the SHA-256 checksum of content held in memory.
+func checksumData(data []byte) string {
+    return fmt.Sprintf("%x", sha256.Sum256(data))
+}
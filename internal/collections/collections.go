@@ -2,58 +2,53 @@
 package collections
 
 import (
+    "bytes"
     "fmt"
     "gopkg.in/yaml.v3"
-    "io"
     "os"
     "path/filepath"
+    "strings"
+
+    "github.com/forsvunnet/project-sync-tool/internal/config"
 )
 
 type CollectionMeta struct {
-    Paths []string `yaml:"paths"`
+    Paths     []string                        `yaml:"paths"`
+    Bases     map[string]map[string]FileState `yaml:"bases,omitempty"`      // Deprecated: base snapshots are kept in project manifests
+    Ignore    []string                        `yaml:"ignore,omitempty"`     // Gitignore-style patterns for files left out of the collection
+    Symlinks  string                          `yaml:"symlinks,omitempty"`   // Policy for symbolic links pointing outside the collection
+    Git       bool                            `yaml:"git,omitempty"`        // The central directory is a git repository with a commit per revision
+    GitRemote string                          `yaml:"git_remote,omitempty"` // Repository the commits are pushed to
+    Rewrites  map[string]string               `yaml:"rewrites,omitempty"`   // Language -> namespace, package or module path central files declare
+    Header    bool                            `yaml:"header,omitempty"`     // Installed files start with a comment naming the collection and revision
+    Hooks     map[string][]string             `yaml:"hooks,omitempty"`      // Hook -> commands run around require, push and sync in every project
+}
+
+// getStorePath returns a path inside the default storage root.
+func getStorePath(elem ...string) string {
+    return filepath.Join(append([]string{config.StoreRoot()}, elem...)...)
 }
 
 // GetCollectionPath returns the path for the central storage of collections
 func GetCollectionPath(collectionName string) string {
-    return filepath.Join(os.Getenv("HOME"), ".config", "project-sync-tool", "collections", collectionName)
+    _, name := splitCollectionName(collectionName)
+    return getCollectionStorePath(collectionName, "collections", name)
 }
 
-func AddToCollection(collectionName string, paths []string, force bool) error {
-    collectionPath := GetCollectionPath(collectionName)
-
-    // Clear and recreate collection directory if force is specified
-    if _, err := os.Stat(collectionPath); err == nil && force {
-        if err := os.RemoveAll(collectionPath); err != nil {
-            return fmt.Errorf("failed to clear collection directory: %w", err)
-        }
-    }
-    if err := os.MkdirAll(collectionPath, os.ModePerm); err != nil {
-        return fmt.Errorf("failed to create collection directory: %w", err)
-    }
-
-    // Copy each specified path into the collection, preserving relative directory structure
-    for _, path := range paths {
-        relPath, err := filepath.Rel(".", path) // relative to the current directory
-        if err != nil {
-            return fmt.Errorf("failed to calculate relative path for %s: %w", path, err)
-        }
-
-        destPath := filepath.Join(collectionPath, relPath)
-        if err := copyToCollection(path, destPath); err != nil {
-            return fmt.Errorf("failed to add %s to collection: %w", path, err)
-        }
-    }
-
-    // Save collection metadata
-    if err := saveCollectionMeta(collectionName); err != nil {
-        return fmt.Errorf("failed to save collection metadata: %w", err)
+// AddToCollection copies the given paths into the central collection and returns the files added.
+// With force, the collection is replaced by the given paths.
+func AddToCollection(collectionName string, paths []string, force bool) ([]FileResult, error) {
+    plan, err := PlanAddToCollection(collectionName, paths, force)
+    if err != nil {
+        return []FileResult{}, err
     }
-    return nil
+    return plan.Apply()
 }
 
 // getMetaFilePath returns the path for storing collection metadata in the meta directory.
 func getMetaFilePath(collectionName string) string {
-    return filepath.Join(os.Getenv("HOME"), ".config", "project-sync-tool", "meta", fmt.Sprintf("%s.yml", collectionName))
+    _, name := splitCollectionName(collectionName)
+    return getCollectionStorePath(collectionName, "meta", fmt.Sprintf("%s.yml", name))
 }
 
 // requireCollectionMeta loads the metadata file if it exists or initializes a new CollectionMeta.
@@ -77,188 +72,140 @@ func requireCollectionMeta(collectionName string) (CollectionMeta, error) {
     return meta, nil
 }
 
-// saveCollectionMeta saves collection paths as relative to the command execution directory, if possible.
-func saveCollectionMeta(collectionName string) error {
-    metaFile := getMetaFilePath(collectionName)
-
-    // Create the meta directory if it doesn't exist
-    metaDir := filepath.Dir(metaFile)
-    if err := os.MkdirAll(metaDir, os.ModePerm); err != nil {
-        return fmt.Errorf("failed to create meta directory: %w", err)
+// saveCollectionMeta registers the project path with the collection and records its base snapshot.
+func saveCollectionMeta(collectionName, projectPath string) error {
+    if err := registerProject(collectionName, projectPath); err != nil {
+        return err
     }
 
+    // Record the files that are now identical in the project and central
+    return RecordBase(collectionName, projectPath)
+}
+
+// registerProject adds the project path to the collection metadata if it isn't listed yet.
+func registerProject(collectionName, projectPath string) error {
     // Require existing metadata if available
     meta, err := requireCollectionMeta(collectionName)
     if err != nil {
         return fmt.Errorf("failed to require existing metadata: %w", err)
     }
 
-    // Get the current working directory where the command is executed
-    commandDir, err := os.Getwd()
+    projectPath, err = filepath.Abs(projectPath)
     if err != nil {
-        return fmt.Errorf("could not determine working directory: %w", err)
+        return fmt.Errorf("could not determine project path: %w", err)
     }
 
-    // Add commandDir if not already in relativePaths
+    // Add projectPath if not already in the registered paths
     alreadyExists := false
     for _, existingPath := range meta.Paths {
-        if existingPath == commandDir {
+        if existingPath == projectPath {
             alreadyExists = true
             break
         }
     }
     if !alreadyExists {
-        meta.Paths = append(meta.Paths, commandDir)
-    }
-
-    // Store the updated paths in the YAML file
-    data, err := yaml.Marshal(&meta)
-    if err != nil {
-        return fmt.Errorf("failed to marshal collection metadata: %w", err)
+        meta.Paths = append(meta.Paths, projectPath)
     }
 
-    return os.WriteFile(metaFile, data, 0644)
+    return writeCollectionMeta(collectionName, meta)
 }
 
+// writeCollectionMeta stores the collection metadata in its YAML file.
+func writeCollectionMeta(collectionName string, meta CollectionMeta) error {
+    metaFile := getMetaFilePath(collectionName)
 
-// copyToCollection copies files or directories to the collection path, creating directories as needed.
-func copyToCollection(srcPath, destPath string) error {
-    // Ensure the destination directory exists
-    destDir := filepath.Dir(destPath)
-    if err := os.MkdirAll(destDir, os.ModePerm); err != nil {
-        return fmt.Errorf("failed to create destination directory %s: %w", destDir, err)
+    // Create the meta directory if it doesn't exist
+    metaDir := filepath.Dir(metaFile)
+    if err := os.MkdirAll(metaDir, os.ModePerm); err != nil {
+        return fmt.Errorf("failed to create meta directory: %w", err)
     }
 
-    srcInfo, err := os.Stat(srcPath)
+    data, err := yaml.Marshal(&meta)
     if err != nil {
-        return fmt.Errorf("could not access path %s: %w", srcPath, err)
+        return fmt.Errorf("failed to marshal collection metadata: %w", err)
     }
 
-    if srcInfo.IsDir() {
-        return copyDirectory(srcPath, destPath)
-    }
-    return CopyFile(srcPath, destPath)
+    return writeFileAtomic(metaFile, bytes.NewReader(data))
 }
 
-// CopyFile copies a single file from src to dst
+
+// CopyFile copies a single file from src to dst, replacing dst atomically and preserving its mode
 func CopyFile(src, dst string) error {
-    in, err := os.Open(src)
-    if err != nil {
-        return err
-    }
-    defer in.Close()
+    return copyToTarget(src, dst)
+}
 
-    out, err := os.Create(dst)
+// RequireCollection requires all files from the collection directory into the current working directory
+// and adds the current working directory to the metadata if it's not already there.
+// It returns the files copied into the project.
+func RequireCollection(collectionName string, cwd string) ([]FileResult, error) {
+    plan, err := PlanRequire(collectionName, cwd)
     if err != nil {
-        return err
+        return nil, err
     }
-    defer out.Close()
-
-    if _, err = io.Copy(out, in); err != nil {
-        return err
-    }
-    return out.Close()
+    return plan.Apply()
 }
 
-func copyDirectory(src, dst string) error {
-    return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-        if err != nil {
-            return err
-        }
-
-        // Calculate the relative path and create target path
-        relPath, err := filepath.Rel(src, path)
-        if err != nil {
-            return err
-        }
-        targetPath := filepath.Join(dst, relPath)
-
-        if info.IsDir() {
-            // Create the target directory if it's a directory
-            return os.MkdirAll(targetPath, info.Mode())
-        }
-
-        // Copy file if it's a regular file
-        return CopyFile(path, targetPath)
-    })
+// copyToTarget copies a single file from srcPath to destPath, creating directories as needed.
+// The destination is replaced atomically, so it never holds a partial copy, and gets the mode of the source.
+func copyToTarget(srcPath, destPath string) error {
+    return convertToTarget(srcPath, destPath, nil)
 }
 
-// RequireCollection requires all files from the collection directory into the current working directory
-// and adds the current working directory to the metadata if it's not already there.
-func RequireCollection(collectionName string, cwd string) error {
-    collectionPath := GetCollectionPath(collectionName)
-
-    // Check if the collection exists
-    if _, err := os.Stat(collectionPath); os.IsNotExist(err) {
-        return fmt.Errorf("collection %s does not exist", collectionName)
+// convertToTarget copies a single file like copyToTarget, with the content passed through convert.
+func convertToTarget(srcPath, destPath string, convert converter) error {
+    tx := &transaction{}
+    if err := tx.stageConverted(srcPath, destPath, 0, convert); err != nil {
+        return tx.fail(fmt.Errorf("failed to copy file from %s to %s: %w", srcPath, destPath, err))
     }
-
-	if cwd == "." {
-		// Get the current working directory (cwd)
-		var err error
-		cwd, err = os.Getwd()
-		if err != nil {
-			return fmt.Errorf("could not determine current working directory: %w", err)
-		}
-	}
-
-    // Copy each file in the collection to the cwd
-    if err := copyCollectionFilesToTarget(collectionPath, cwd); err != nil {
-        return fmt.Errorf("failed to copy files to current directory: %w", err)
+    if err := tx.commit(); err != nil {
+        return err
     }
-
-	saveCollectionMeta(collectionName)
+    tx.cleanup()
     return nil
 }
 
-func copyCollectionFilesToTarget(collectionPath, targetPath string) error {
-    return filepath.Walk(collectionPath, func(path string, info os.FileInfo, err error) error {
-        if err != nil {
-            return err
-        }
-
-        // Calculate the relative path from the collection base to the current file
-        relPath, err := filepath.Rel(collectionPath, path)
-        if err != nil {
-            return fmt.Errorf("failed to calculate relative path: %w", err)
-        }
-        destPath := filepath.Join(targetPath, relPath)
-
-        if info.IsDir() {
-            // Create the directory in the target path
-            return os.MkdirAll(destPath, info.Mode())
-        }
-
-        // Copy files using CopyFile
-        return CopyFile(path, destPath)
-    })
-}
-
-// copyToTarget copies a single file from srcPath to destPath, creating directories as needed.
-func copyToTarget(srcPath, destPath string) error {
-    // Create target directory if it doesn't exist
-    if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
-        return fmt.Errorf("failed to create directory for %s: %w", destPath, err)
-    }
-
-    // Open source file
-    srcFile, err := os.Open(srcPath)
+// PushFiles copies the given project files into the central collection and returns the files pushed.
+// Files that no longer exist in the project are deleted from central.
+func PushFiles(collectionName, projectPath string, files []string) ([]FileResult, error) {
+    plan, err := PlanPush(collectionName, projectPath, files)
     if err != nil {
-        return fmt.Errorf("failed to open source file %s: %w", srcPath, err)
+        return []FileResult{}, err
     }
-    defer srcFile.Close()
+    return plan.Apply()
+}
 
-    // Open destination file
-    destFile, err := os.Create(destPath)
+// PullFiles copies the central versions of the given project files into the project and returns the files pulled.
+// Files that no longer exist in central are deleted from the project.
+func PullFiles(collectionName, projectPath string, files []string) ([]FileResult, error) {
+    plan, err := PlanPull(collectionName, projectPath, files)
     if err != nil {
-        return fmt.Errorf("failed to create destination file %s: %w", destPath, err)
+        return []FileResult{}, err
     }
-    defer destFile.Close()
+    return plan.Apply()
+}
 
-    // Copy contents from source to destination
-    if _, err = io.Copy(destFile, srcFile); err != nil {
-        return fmt.Errorf("failed to copy file from %s to %s: %w", srcPath, destPath, err)
+// removeFile deletes a file and any parent directories left empty, up to but not including root.
+func removeFile(path, root string) error {
+    if err := journalFile(path); err != nil {
+        return err
     }
+    if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+        return err
+    }
+    return removeEmptyParents(path, root)
+}
 
+// removeEmptyParents deletes the parent directories of path left empty, up to but not including root.
+func removeEmptyParents(path, root string) error {
+    root = filepath.Clean(root)
+    for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+        entries, err := os.ReadDir(dir)
+        if err != nil || len(entries) > 0 {
+            break
+        }
+        if err := os.Remove(dir); err != nil {
+            return err
+        }
+    }
     return nil
 }
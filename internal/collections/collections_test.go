@@ -1 +1,1449 @@
 package collections
+
+import (
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/forsvunnet/project-sync-tool/internal/config"
+)
+
+// setupProject points HOME and the store at a temporary directory and changes into a fresh project directory.
+func setupProject(t *testing.T) string {
+    t.Helper()
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    t.Setenv("PST_HOME", filepath.Join(home, "store"))
+    t.Setenv("XDG_CONFIG_HOME", "")
+
+    project := filepath.Join(home, "project")
+    if err := os.MkdirAll(project, os.ModePerm); err != nil {
+        t.Fatal(err)
+    }
+
+    wd, err := os.Getwd()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Chdir(project); err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { os.Chdir(wd) })
+    return project
+}
+
+func writeFile(t *testing.T, path, content string) {
+    t.Helper()
+    if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestCheckForChangesUsesBase(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "local.txt"), "one")
+    writeFile(t, filepath.Join(project, "central.txt"), "one")
+    writeFile(t, filepath.Join(project, "both.txt"), "one")
+    writeFile(t, filepath.Join(project, "same.txt"), "one")
+
+    if _, err := AddToCollection("utils", []string{"local.txt", "central.txt", "both.txt", "same.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+
+    central := GetCollectionPath("utils")
+    writeFile(t, filepath.Join(project, "local.txt"), "two")
+    writeFile(t, filepath.Join(central, "central.txt"), "two")
+    writeFile(t, filepath.Join(project, "both.txt"), "two")
+    writeFile(t, filepath.Join(central, "both.txt"), "three")
+
+    status, err := CheckForChanges("utils", project)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    expect := func(name string, got []string, want string) {
+        t.Helper()
+        if len(got) != 1 || got[0] != filepath.Join(project, want) {
+            t.Errorf("%s = %v, want [%s]", name, got, want)
+        }
+    }
+    expect("LocalNewer", status.LocalNewer, "local.txt")
+    expect("CentralNewer", status.CentralNewer, "central.txt")
+    expect("Conflicts", status.Conflicts, "both.txt")
+}
+
+func TestCheckForChanges(t *testing.T) {
+    project := setupProject(t)
+    central := ""
+    tests := []struct {
+        file   string
+        change func(path string)
+        want   string
+    }{
+        {"same.txt", func(path string) {}, "Unchanged"},
+        {"local.txt", func(path string) { writeFile(t, path, "two") }, "LocalNewer"},
+        {"local-deleted.txt", func(path string) { os.Remove(path) }, "LocalDeleted"},
+        {"central.txt", func(path string) { writeFile(t, filepath.Join(central, "central.txt"), "two") }, "CentralNewer"},
+        {"central-deleted.txt", func(path string) { os.Remove(filepath.Join(central, "central-deleted.txt")) }, "CentralDeleted"},
+        {"both.txt", func(path string) {
+            writeFile(t, path, "two")
+            writeFile(t, filepath.Join(central, "both.txt"), "three")
+        }, "Conflicts"},
+        {"changed-deleted.txt", func(path string) {
+            writeFile(t, path, "two")
+            os.Remove(filepath.Join(central, "changed-deleted.txt"))
+        }, "Conflicts"},
+        {"both-same.txt", func(path string) {
+            writeFile(t, path, "two")
+            writeFile(t, filepath.Join(central, "both-same.txt"), "two")
+        }, "Unchanged"},
+        {"new.txt", func(path string) {
+            os.Remove(path)
+            writeFile(t, filepath.Join(central, "new.txt"), "two")
+        }, "MissingLocal"},
+        {"unrecorded.txt", func(path string) {
+            writeFile(t, path, "two")
+            writeFile(t, filepath.Join(central, "unrecorded.txt"), "three")
+        }, "Conflicts"},
+    }
+
+    // Files added afterwards have no recorded base
+    files := []string{}
+    for _, test := range tests {
+        writeFile(t, filepath.Join(project, test.file), "one")
+        if test.file != "new.txt" && test.file != "unrecorded.txt" {
+            files = append(files, test.file)
+        }
+    }
+    if _, err := AddToCollection("utils", files, false); err != nil {
+        t.Fatal(err)
+    }
+    central = GetCollectionPath("utils")
+    for _, test := range tests {
+        test.change(filepath.Join(project, test.file))
+    }
+
+    status, err := CheckForChanges("utils", project)
+    if err != nil {
+        t.Fatal(err)
+    }
+    states := map[string][]string{
+        "LocalNewer":     status.LocalNewer,
+        "LocalDeleted":   status.LocalDeleted,
+        "CentralNewer":   status.CentralNewer,
+        "CentralDeleted": status.CentralDeleted,
+        "Conflicts":      status.Conflicts,
+        "Unchanged":      status.Unchanged,
+        "MissingLocal":   status.MissingLocal,
+    }
+    for _, test := range tests {
+        path := filepath.Join(project, test.file)
+        for state, files := range states {
+            found := false
+            for _, file := range files {
+                found = found || file == path
+            }
+            if found != (state == test.want) {
+                t.Errorf("%s: listed in %s = %v, want it only in %s", test.file, state, found, test.want)
+            }
+        }
+    }
+    if status.InSync() {
+        t.Error("InSync = true, want false")
+    }
+}
+
+func TestFindSyncConflicts(t *testing.T) {
+    project := setupProject(t)
+    for _, name := range []string{"local.txt", "central.txt", "both.txt"} {
+        writeFile(t, filepath.Join(project, name), "one")
+    }
+    if _, err := AddToCollection("utils", []string{"local.txt", "central.txt", "both.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+    other := filepath.Join(filepath.Dir(project), "other")
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+
+    central := GetCollectionPath("utils")
+    writeFile(t, filepath.Join(project, "local.txt"), "two")
+    writeFile(t, filepath.Join(central, "central.txt"), "two")
+    writeFile(t, filepath.Join(project, "both.txt"), "two")
+    writeFile(t, filepath.Join(central, "both.txt"), "three")
+    writeFile(t, filepath.Join(other, "local.txt"), "four")
+
+    targets := []SyncTarget{}
+    for _, path := range []string{project, other} {
+        status, err := CheckForChanges("utils", path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        targets = append(targets, SyncTarget{Collection: "utils", Project: path, Status: status})
+    }
+
+    tests := []struct {
+        name    string
+        targets []SyncTarget
+        update  bool
+        want    []string
+    }{
+        {"both sides without update", targets[:1], false, []string{filepath.Join(project, "both.txt") + " (utils)"}},
+        {"both sides with update", targets[:1], true, []string{filepath.Join(project, "both.txt") + " (utils)"}},
+        {"local only", targets[1:], true, []string{}},
+        {"two projects pushing", targets, true, []string{filepath.Join(project, "both.txt") + " (utils)", "local.txt (utils)"}},
+        {"two projects without update", targets, false, []string{filepath.Join(project, "both.txt") + " (utils)"}},
+    }
+    for _, test := range tests {
+        conflicts, err := FindSyncConflicts(test.targets, test.update, false)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if strings.Join(conflicts, "|") != strings.Join(test.want, "|") {
+            t.Errorf("%s: conflicts = %v, want %v", test.name, conflicts, test.want)
+        }
+    }
+
+    // Files changed on one side only are left to push and pull
+    status := targets[0].Status
+    if len(status.LocalNewer) != 1 || len(status.CentralNewer) != 1 || len(status.Conflicts) != 1 {
+        t.Errorf("status = %+v, want one local, one central and one conflicting file", status)
+    }
+}
+
+func TestListProjectPaths(t *testing.T) {
+    project := setupProject(t)
+    if paths, err := ListProjectPaths(); err != nil || len(paths) != 0 {
+        t.Fatalf("ListProjectPaths on a fresh store = %v, %v, want no paths", paths, err)
+    }
+
+    writeFile(t, filepath.Join(project, "a.txt"), "a")
+    if _, err := AddToCollection("utils", []string{"a.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+    other := filepath.Join(filepath.Dir(project), "other")
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.RemoveAll(other); err != nil {
+        t.Fatal(err)
+    }
+
+    // Deleted projects are left out so a global sync doesn't recreate them
+    paths, err := ListProjectPaths()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(paths) != 1 || paths[0] != project {
+        t.Errorf("ListProjectPaths = %v, want [%s]", paths, project)
+    }
+}
+
+func TestUnifiedDiff(t *testing.T) {
+    from := []byte("a\nb\nc\n")
+    to := []byte("a\nB\nc\nd")
+
+    want := "--- old\n+++ new\n@@ -1,3 +1,4 @@\n a\n-b\n+B\n c\n+d\n\\ No newline at end of file\n"
+    if got := UnifiedDiff("old", "new", from, to); got != want {
+        t.Errorf("UnifiedDiff =\n%s\nwant\n%s", got, want)
+    }
+    if got := UnifiedDiff("old", "new", from, from); got != "" {
+        t.Errorf("UnifiedDiff of identical content = %q, want empty", got)
+    }
+}
+
+func TestDiffLines(t *testing.T) {
+    // Edit scripts turn one file into the other with as few edits as a longest common subsequence allows
+    apply := func(ops []diffOp) (string, string, int) {
+        var from, to strings.Builder
+        edits := 0
+        for _, op := range ops {
+            if op.kind != '+' {
+                from.WriteString(op.line)
+            }
+            if op.kind != '-' {
+                to.WriteString(op.line)
+            }
+            if op.kind != ' ' {
+                edits++
+            }
+        }
+        return from.String(), to.String(), edits
+    }
+    lcs := func(a, b []string) int {
+        table := make([][]int, len(a)+1)
+        for i := range table {
+            table[i] = make([]int, len(b)+1)
+        }
+        for i := len(a) - 1; i >= 0; i-- {
+            for j := len(b) - 1; j >= 0; j-- {
+                if a[i] == b[j] {
+                    table[i][j] = table[i+1][j+1] + 1
+                } else {
+                    table[i][j] = max(table[i+1][j], table[i][j+1])
+                }
+            }
+        }
+        return table[0][0]
+    }
+
+    seed := uint32(1)
+    random := func(n int) []string {
+        lines := make([]string, n)
+        for i := range lines {
+            seed = seed*1664525 + 1013904223
+            lines[i] = string(rune('a'+(seed>>29)%3)) + "\n"
+        }
+        return lines
+    }
+    for i := 0; i < 200; i++ {
+        a, b := random(i%17), random(i%23)
+        from, to, edits := apply(diffLines(a, b))
+        if from != strings.Join(a, "") || to != strings.Join(b, "") {
+            t.Fatalf("diffLines(%q, %q) doesn't turn one into the other", a, b)
+        }
+        if want := len(a) + len(b) - 2*lcs(a, b); edits != want {
+            t.Errorf("diffLines(%q, %q) made %d edits, want %d", a, b, edits, want)
+        }
+    }
+
+    // Large unrelated files are replaced as a whole instead of searched
+    a, b := make([]string, 200000), make([]string, 200000)
+    for i := range a {
+        a[i], b[i] = fmt.Sprintf("a%d\n", i), fmt.Sprintf("b%d\n", i)
+    }
+    from, to, edits := apply(diffLines(a, b))
+    if from != strings.Join(a, "") || to != strings.Join(b, "") || edits != len(a)+len(b) {
+        t.Errorf("diffLines of unrelated files made %d edits, want %d", edits, len(a)+len(b))
+    }
+}
+
+func TestCheckForChangesDetectsDeletions(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "local.txt"), "one")
+    writeFile(t, filepath.Join(project, "central.txt"), "one")
+
+    if _, err := AddToCollection("utils", []string{"local.txt", "central.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+
+    if err := os.Remove(filepath.Join(project, "local.txt")); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Remove(filepath.Join(GetCollectionPath("utils"), "central.txt")); err != nil {
+        t.Fatal(err)
+    }
+
+    status, err := CheckForChanges("utils", project)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(status.LocalDeleted) != 1 || status.LocalDeleted[0] != filepath.Join(project, "local.txt") {
+        t.Errorf("LocalDeleted = %v, want [local.txt]", status.LocalDeleted)
+    }
+    if len(status.CentralDeleted) != 1 || status.CentralDeleted[0] != filepath.Join(project, "central.txt") {
+        t.Errorf("CentralDeleted = %v, want [central.txt]", status.CentralDeleted)
+    }
+
+    // Pushing the local deletion removes the central copy
+    if _, err := PushFiles("utils", project, status.LocalDeleted); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(filepath.Join(GetCollectionPath("utils"), "local.txt")); !os.IsNotExist(err) {
+        t.Errorf("central local.txt still exists after pushing its deletion")
+    }
+}
+
+func TestRequireRecordsManifest(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "utils.txt"), "one")
+    if _, err := AddToCollection("utils", []string{"utils.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+
+    other := filepath.Join(filepath.Dir(project), "other")
+    if err := os.MkdirAll(other, os.ModePerm); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Chdir(other); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RequireCollection("utils", filepath.Join(other, "lib")); err != nil {
+        t.Fatal(err)
+    }
+
+    manifest, err := LoadManifest(other)
+    if err != nil {
+        t.Fatal(err)
+    }
+    entry := manifest.Collections["utils"]
+    if entry == nil || entry.Target != "lib" || entry.Files["utils.txt"].Checksum == "" {
+        t.Fatalf("manifest entry = %+v, want target lib with a base for utils.txt", entry)
+    }
+
+    names, err := ScanForCollections(other)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(names) != 1 || names[0] != "utils" {
+        t.Errorf("ScanForCollections = %v, want [utils]", names)
+    }
+    if path, _ := ResolveProjectPath("utils", other); path != filepath.Join(other, "lib") {
+        t.Errorf("ResolveProjectPath = %s, want %s", path, filepath.Join(other, "lib"))
+    }
+}
+
+func TestManifestStaysInProject(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "a.txt"), "a")
+    writeFile(t, filepath.Join(project, "b.txt"), "b")
+    if _, err := AddToCollection("utils", []string{"a.txt", "b.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+    parentManifest, err := os.ReadFile(filepath.Join(project, ManifestFileName))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // A project nested in another gets its own manifest
+    nested := filepath.Join(project, "nested")
+    if err := os.MkdirAll(nested, os.ModePerm); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Chdir(nested); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RequireCollection("utils", nested); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(filepath.Join(nested, ManifestFileName)); err != nil {
+        t.Errorf("nested project has no manifest: %v", err)
+    }
+    if data, _ := os.ReadFile(filepath.Join(project, ManifestFileName)); string(data) != string(parentManifest) {
+        t.Errorf("requiring into a nested project changed the parent manifest:\n%s", data)
+    }
+
+    // Removing files updates existing manifests and adds none
+    other := filepath.Join(filepath.Dir(project), "other")
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Remove(filepath.Join(other, ManifestFileName)); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RemoveFromCollection("utils", "a.txt", false); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(filepath.Join(other, ManifestFileName)); !os.IsNotExist(err) {
+        t.Errorf("remove added a manifest to %s", other)
+    }
+    manifest, err := LoadManifest(nested)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if files := manifest.Collections["utils"].Files; len(files) != 1 || files["b.txt"].Checksum == "" {
+        t.Errorf("nested manifest files = %v, want only b.txt", files)
+    }
+}
+
+func TestAddAndRemovePaths(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "a.txt"), "a")
+    writeFile(t, filepath.Join(project, "..env"), "env")
+    writeFile(t, filepath.Join(filepath.Dir(project), "outside.txt"), "outside")
+    if _, err := AddToCollection("utils", []string{"a.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+    central := GetCollectionPath("utils")
+
+    if _, err := AddPaths("missing", []string{"a.txt"}, false); err == nil {
+        t.Error("AddPaths to a missing collection succeeded, want an error")
+    }
+    if _, err := AddPaths("utils", []string{"..env"}, false); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(filepath.Join(central, "..env")); err != nil {
+        t.Errorf("..env is not in central after adding it: %v", err)
+    }
+    for _, path := range []string{"a.txt", filepath.Join(project, "a.txt")} {
+        if _, err := AddPaths("utils", []string{path}, false); err == nil {
+            t.Errorf("adding %s, which is already in the collection, succeeded, want an error", path)
+        }
+    }
+    if _, err := AddPaths("utils", []string{filepath.Join("..", "outside.txt")}, false); err == nil {
+        t.Error("adding a path outside the project succeeded, want an error")
+    }
+    if _, err := os.Stat(filepath.Join(filepath.Dir(central), "outside.txt")); !os.IsNotExist(err) {
+        t.Error("adding a path outside the project wrote outside the collection")
+    }
+
+    other := filepath.Join(filepath.Dir(project), "other")
+    if err := os.MkdirAll(other, os.ModePerm); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+
+    for _, path := range []string{"missing.txt", "..", filepath.Join("..", "utils")} {
+        if _, err := RemoveFromCollection("utils", path, false); err == nil {
+            t.Errorf("RemoveFromCollection(%q) succeeded, want an error", path)
+        }
+    }
+    if _, err := RemoveFromCollection("utils", "..env", true); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(filepath.Join(central, "..env")); !os.IsNotExist(err) {
+        t.Error("..env is still in central after removing it")
+    }
+    if _, err := os.Stat(filepath.Join(other, "..env")); !os.IsNotExist(err) {
+        t.Error("the unmodified copy of ..env was kept")
+    }
+    if _, err := os.Stat(filepath.Join(other, "a.txt")); err != nil {
+        t.Errorf("a.txt was deleted from the project: %v", err)
+    }
+    manifest, err := LoadManifest(other)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if entry := manifest.Collections["utils"]; entry == nil || len(entry.Files) != 1 {
+        t.Errorf("manifest entry = %+v, want only a.txt", entry)
+    }
+}
+
+func TestMapPath(t *testing.T) {
+    mappings := map[string]string{
+        "src":       "lib",
+        "src/app":   "app",
+        "utils.php": "helpers/utils.php",
+    }
+    cases := map[string]string{
+        "src/a.php":     filepath.Join("lib", "a.php"),
+        "src/app/b.php": filepath.Join("app", "b.php"),
+        "utils.php":     filepath.Join("helpers", "utils.php"),
+        "srcfile.php":   "srcfile.php",
+    }
+    for relPath, want := range cases {
+        if got := mapPath(filepath.FromSlash(relPath), mappings); got != want {
+            t.Errorf("mapPath(%s) = %s, want %s", relPath, got, want)
+        }
+    }
+}
+
+func TestMappingsAreCleaned(t *testing.T) {
+    project := setupProject(t)
+    for _, from := range []string{"src/", "./src", "src/."} {
+        if got := mapPath(filepath.Join("src", "a.php"), map[string]string{from: "lib/"}); got != filepath.Join("lib", "a.php") {
+            t.Errorf("mapPath with key %q = %s, want lib/a.php", from, got)
+        }
+    }
+
+    // Mappings written by hand into the manifest are cleaned when loaded
+    writeFile(t, filepath.Join(project, "src", "a.php"), "a")
+    if _, err := AddToCollection("utils", []string{"src"}, false); err != nil {
+        t.Fatal(err)
+    }
+    other := filepath.Join(filepath.Dir(project), "other")
+    err := updateManifestEntry("utils", other, func(entry *ManifestEntry) {
+        entry.Mappings = map[string]string{"./src/": "./lib/"}
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(filepath.Join(other, "lib", "a.php")); err != nil {
+        t.Errorf("src/a.php not installed at lib/a.php: %v", err)
+    }
+
+    for _, mapping := range []map[string]string{{"../src": "lib"}, {"/src": "lib"}, {"src": "../lib"}, {"src": "/lib"}} {
+        if err := SetMappings("utils", other, mapping); err == nil {
+            t.Errorf("SetMappings accepted %v", mapping)
+        }
+    }
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+    matcher, err := newIgnoreMatcher([]string{"# comment", "node_modules/", "*.swp", "/build", "docs/**/*.tmp", "!keep.swp"})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    cases := []struct {
+        path    string
+        isDir   bool
+        ignored bool
+    }{
+        {"node_modules", true, true},
+        {"lib/node_modules/pkg/index.js", false, true},
+        {"node_modules", false, false},
+        {"lib/.main.go.swp", false, true},
+        {"keep.swp", false, false},
+        {"build/out.js", false, true},
+        {"lib/build/out.js", false, false},
+        {"docs/a/b/c.tmp", false, true},
+        {"lib/main.go", false, false},
+    }
+    for _, c := range cases {
+        if got := matcher.Match(c.path, c.isDir); got != c.ignored {
+            t.Errorf("Match(%s, %v) = %v, want %v", c.path, c.isDir, got, c.ignored)
+        }
+    }
+}
+
+func TestSetIgnorePatterns(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "a.txt"), "a")
+    writeFile(t, filepath.Join(project, "debug.log"), "log")
+    if err := AddIgnorePatterns("utils", []string{"*.log", "*.log"}); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := AddToCollection("utils", []string{"."}, false); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(filepath.Join(GetCollectionPath("utils"), "debug.log")); !os.IsNotExist(err) {
+        t.Error("debug.log was added despite the ignore pattern")
+    }
+
+    // Rebuilding the collection drops the patterns of its previous version
+    if err := SetIgnorePatterns("utils", nil); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := AddToCollection("utils", []string{"."}, true); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(filepath.Join(GetCollectionPath("utils"), "debug.log")); err != nil {
+        t.Errorf("debug.log is missing after clearing the ignore patterns: %v", err)
+    }
+    meta, err := requireCollectionMeta("utils")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(meta.Ignore) != 0 {
+        t.Errorf("Ignore = %v after clearing, want none", meta.Ignore)
+    }
+}
+
+func TestRevisionHistory(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "a.txt"), "one")
+    if _, err := AddToCollection("utils", []string{"a.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+    first, err := RecordRevision("utils", "first")
+    if err != nil || first == nil {
+        t.Fatalf("RecordRevision = %v, %v", first, err)
+    }
+    if unchanged, err := RecordRevision("utils", "again"); err != nil || unchanged != nil {
+        t.Fatalf("RecordRevision without changes = %v, %v", unchanged, err)
+    }
+
+    writeFile(t, filepath.Join(GetCollectionPath("utils"), "a.txt"), "two")
+    second, err := RecordRevision("utils", "second")
+    if err != nil || second == nil || second.Parent != first.ID {
+        t.Fatalf("RecordRevision = %v, %v", second, err)
+    }
+    if len(second.Changes) != 1 || second.Changes[0].Action != ActionModified {
+        t.Errorf("Changes = %v, want a.txt modified", second.Changes)
+    }
+
+    history, err := GetHistory("utils")
+    if err != nil || len(history) != 2 || history[0].ID != second.ID {
+        t.Fatalf("GetHistory = %v, %v", history, err)
+    }
+
+    // Installing the first revision makes the newer central file show up as a central change
+    other := filepath.Join(filepath.Dir(project), "other")
+    if _, err := RequireRevision("utils", first.ShortID(), other); err != nil {
+        t.Fatal(err)
+    }
+    if data, _ := os.ReadFile(filepath.Join(other, "a.txt")); string(data) != "one" {
+        t.Errorf("a.txt = %q, want %q", data, "one")
+    }
+    status, err := CheckForChanges("utils", other)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(status.CentralNewer) != 1 || len(status.Conflicts) != 0 {
+        t.Errorf("status = %+v, want a.txt central ahead", status)
+    }
+
+    // Files added after a revision are deleted when it is installed, unless they were changed locally
+    writeFile(t, filepath.Join(project, "b.txt"), "b")
+    writeFile(t, filepath.Join(project, "c.txt"), "c")
+    if _, err := AddPaths("utils", []string{"b.txt", "c.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+    writeFile(t, filepath.Join(other, "c.txt"), "changed")
+    if _, err := RequireRevision("utils", second.ShortID(), other); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(filepath.Join(other, "b.txt")); !os.IsNotExist(err) {
+        t.Error("b.txt, which the revision doesn't have, was kept")
+    }
+    if data, _ := os.ReadFile(filepath.Join(other, "c.txt")); string(data) != "changed" {
+        t.Errorf("c.txt = %q, want the local change kept", data)
+    }
+    entry, err := findManifestEntry("utils", other)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, ok := entry.Files["b.txt"]; ok || len(entry.Files) != 2 {
+        t.Errorf("base = %v, want a.txt and the changed c.txt", entry.Files)
+    }
+}
+
+func TestParseConstraint(t *testing.T) {
+    cases := []struct {
+        constraint string
+        version    string
+        allowed    bool
+    }{
+        {"^1.2", "v1.2.0", true},
+        {"^1.2", "v1.9.3", true},
+        {"^1.2", "v1.1.9", false},
+        {"^1.2", "v2.0.0", false},
+        {"^1.2", "v1.3.0-rc.1", false},
+        {"^0.3", "v0.3.4", true},
+        {"^0.3", "v0.4.0", false},
+        {"~1.2.3", "v1.2.9", true},
+        {"~1.2.3", "v1.3.0", false},
+        {"1.2", "v1.2.5", true},
+        {"v1", "v1.7.0", true},
+        {"v1.2.3", "v1.2.3", true},
+        {"v1.2.3", "v1.2.4", false},
+    }
+    for _, c := range cases {
+        constraint, err := ParseConstraint(c.constraint)
+        if err != nil {
+            t.Fatalf("ParseConstraint(%s): %v", c.constraint, err)
+        }
+        version, err := ParseVersion(c.version)
+        if err != nil {
+            t.Fatalf("ParseVersion(%s): %v", c.version, err)
+        }
+        if got := constraint.Allows(version); got != c.allowed {
+            t.Errorf("%s allows %s = %v, want %v", c.constraint, c.version, got, c.allowed)
+        }
+    }
+
+    if IsVersionConstraint("3f2a9c1b") || IsVersionConstraint("1234") || !IsVersionConstraint("^1") {
+        t.Error("IsVersionConstraint doesn't tell revisions from versions")
+    }
+}
+
+func TestPinIsWrittenWithFiles(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "a.txt"), "one")
+    if _, err := AddToCollection("utils", []string{"a.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RecordRevision("utils", "first"); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := TagRevision("utils", "v1.0.0", "", false); err != nil {
+        t.Fatal(err)
+    }
+    writeFile(t, filepath.Join(project, "a.txt"), "two")
+    if _, err := PushFiles("utils", project, []string{filepath.Join(project, "a.txt")}); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RecordRevision("utils", "second"); err != nil {
+        t.Fatal(err)
+    }
+    other := filepath.Join(filepath.Dir(project), "other")
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+
+    pin, err := PlanPin("utils", other, "^1.0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if pin.Tag != "v1.0.0" {
+        t.Fatalf("PlanPin selected %q, want v1.0.0", pin.Tag)
+    }
+
+    // Checks made while previewing the pin compare with the pinned version, without writing it
+    restore := pin.Preview()
+    status, err := CheckForChanges("utils", other)
+    restore()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(status.CentralNewer) != 1 {
+        t.Errorf("CentralNewer = %v while previewing the pin, want [a.txt]", status.CentralNewer)
+    }
+    if entry, _ := findManifestEntry("utils", other); entry == nil || entry.Version != "" || entry.Revision != "" {
+        t.Errorf("manifest entry = %+v after previewing the pin, want it unpinned", entry)
+    }
+
+    // Applying the plan writes the pin along with the files
+    restore = pin.Preview()
+    plan, err := PlanRequire("utils", other)
+    if err == nil {
+        plan.SetPin(pin)
+        _, err = plan.Apply()
+    }
+    restore()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if data, _ := os.ReadFile(filepath.Join(other, "a.txt")); string(data) != "one" {
+        t.Errorf("a.txt = %q after pinning, want %q", data, "one")
+    }
+    if entry, _ := findManifestEntry("utils", other); entry == nil || entry.Version != "^1.0" || entry.Revision != pin.Revision {
+        t.Errorf("manifest entry = %+v after pinning, want version ^1.0", entry)
+    }
+    if status, err := CheckForChanges("utils", other); err != nil || !status.InSync() {
+        t.Errorf("status after pinning = %+v, %v, want in sync", status, err)
+    }
+}
+
+func TestMergeText(t *testing.T) {
+    base := "one\ntwo\nthree\nfour\n"
+
+    merged, conflicts := MergeText([]byte(base), []byte("ONE\ntwo\nthree\nfour\n"), []byte("one\ntwo\nthree\nFOUR\nfive\n"))
+    if conflicts != 0 || string(merged) != "ONE\ntwo\nthree\nFOUR\nfive\n" {
+        t.Errorf("clean merge = %q with %d conflicts", merged, conflicts)
+    }
+
+    merged, conflicts = MergeText([]byte(base), []byte("one\n2\nthree\nfour\n"), []byte("one\nII\nthree\nfour\n"))
+    want := "one\n<<<<<<< project\n2\n=======\nII\n>>>>>>> central\nthree\nfour\n"
+    if conflicts != 1 || string(merged) != want {
+        t.Errorf("conflicting merge = %q with %d conflicts, want %q", merged, conflicts, want)
+    }
+    if !HasConflictMarkers(merged) {
+        t.Error("HasConflictMarkers = false for a conflicting merge")
+    }
+}
+
+func TestRequireKeepsLocalDeletions(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "a.txt"), "a")
+    writeFile(t, filepath.Join(project, "b.txt"), "b")
+    if _, err := AddToCollection("utils", []string{"a.txt", "b.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+    other := filepath.Join(filepath.Dir(project), "other")
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Remove(filepath.Join(other, "b.txt")); err != nil {
+        t.Fatal(err)
+    }
+
+    status, err := CheckForChanges("utils", other)
+    if err != nil {
+        t.Fatal(err)
+    }
+    plan, err := PlanRequire("utils", other)
+    if err != nil {
+        t.Fatal(err)
+    }
+    plan.Keep(status.LocalDeleted)
+    for _, file := range plan.Changes() {
+        if file.Path == "b.txt" {
+            t.Errorf("planned %s for b.txt, which was deleted locally", file.Action)
+        }
+    }
+    if _, err := plan.Apply(); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(filepath.Join(other, "b.txt")); !os.IsNotExist(err) {
+        t.Error("require restored b.txt, which was deleted locally")
+    }
+
+    // The deletion is still pending, ready to push
+    status, err = CheckForChanges("utils", other)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(status.LocalDeleted) != 1 || status.LocalDeleted[0] != filepath.Join(other, "b.txt") {
+        t.Errorf("LocalDeleted = %v after require, want [b.txt]", status.LocalDeleted)
+    }
+}
+
+func TestPlanRequire(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "same.txt"), "one")
+    writeFile(t, filepath.Join(project, "changed.txt"), "one")
+    writeFile(t, filepath.Join(project, "new.txt"), "one")
+    if _, err := AddToCollection("utils", []string{"same.txt", "changed.txt", "new.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+
+    other := filepath.Join(filepath.Dir(project), "other")
+    writeFile(t, filepath.Join(other, "same.txt"), "one")
+    writeFile(t, filepath.Join(other, "changed.txt"), "two")
+
+    plan, err := PlanRequire("utils", other)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := map[string]string{"same.txt": PlanSkip, "changed.txt": PlanOverwrite, "new.txt": PlanCreate}
+    for _, file := range plan.Changes() {
+        if want[file.Path] != file.Action {
+            t.Errorf("planned %s for %s, want %s", file.Action, file.Path, want[file.Path])
+        }
+    }
+    if _, err := os.Stat(filepath.Join(other, "new.txt")); !os.IsNotExist(err) {
+        t.Error("planning wrote new.txt")
+    }
+
+    if _, err := plan.Apply(); err != nil {
+        t.Fatal(err)
+    }
+    if data, _ := os.ReadFile(filepath.Join(other, "changed.txt")); string(data) != "one" {
+        t.Errorf("changed.txt = %q after applying, want %q", data, "one")
+    }
+}
+
+func TestApplyRollsBack(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "source.txt"), "new")
+    writeFile(t, filepath.Join(project, "a.txt"), "old")
+    writeFile(t, filepath.Join(project, "b.txt", "blocker"), "")
+
+    // Replacing the directory at b.txt fails after a.txt has been replaced
+    plan := &Plan{Files: []PlannedFile{
+        {Path: "a.txt", Action: PlanOverwrite, Source: filepath.Join(project, "source.txt"), Target: filepath.Join(project, "a.txt"), result: ActionPushed},
+        {Path: "new/c.txt", Action: PlanCreate, Source: filepath.Join(project, "source.txt"), Target: filepath.Join(project, "new", "c.txt"), result: ActionPushed},
+        {Path: "b.txt", Action: PlanOverwrite, Source: filepath.Join(project, "source.txt"), Target: filepath.Join(project, "b.txt"), result: ActionPushed},
+    }}
+    if _, err := plan.Apply(); err == nil {
+        t.Fatal("Apply succeeded, want an error")
+    }
+
+    if data, _ := os.ReadFile(filepath.Join(project, "a.txt")); string(data) != "old" {
+        t.Errorf("a.txt = %q after rollback, want %q", data, "old")
+    }
+    if _, err := os.Stat(filepath.Join(project, "new")); !os.IsNotExist(err) {
+        t.Error("rollback left the created directory behind")
+    }
+    entries, _ := os.ReadDir(project)
+    if len(entries) != 3 {
+        t.Errorf("project holds %d entries after rollback, want 3 without temporary files", len(entries))
+    }
+}
+
+func TestRequireRollsBack(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "a.txt"), "old")
+    writeFile(t, filepath.Join(project, "gone.txt"), "old")
+    if _, err := AddToCollection("utils", []string{"a.txt", "gone.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+    other := filepath.Join(filepath.Dir(project), "other")
+    if err := os.MkdirAll(other, os.ModePerm); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+
+    // Central changes a.txt and deletes gone.txt
+    writeFile(t, filepath.Join(project, "a.txt"), "new")
+    if err := os.Remove(filepath.Join(project, "gone.txt")); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := PushFiles("utils", project, []string{filepath.Join(project, "a.txt"), filepath.Join(project, "gone.txt")}); err != nil {
+        t.Fatal(err)
+    }
+    manifest, _ := os.ReadFile(filepath.Join(other, ManifestFileName))
+    meta, _ := os.ReadFile(getMetaFilePath("utils"))
+
+    // The bookkeeping fails after it has written the manifest and the metadata
+    plan, err := PlanRequire("utils", other)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := plan.AddPull([]string{filepath.Join(other, "gone.txt")}); err != nil {
+        t.Fatal(err)
+    }
+    finish := plan.finish
+    plan.finish = func() error {
+        if err := finish(); err != nil {
+            return err
+        }
+        return fmt.Errorf("bookkeeping failed")
+    }
+    if _, err := plan.Apply(); err == nil {
+        t.Fatal("Apply succeeded, want an error")
+    }
+
+    if data, _ := os.ReadFile(filepath.Join(other, "a.txt")); string(data) != "old" {
+        t.Errorf("a.txt = %q after rollback, want %q", data, "old")
+    }
+    if data, _ := os.ReadFile(filepath.Join(other, "gone.txt")); string(data) != "old" {
+        t.Errorf("gone.txt = %q after rollback, want %q", data, "old")
+    }
+    if data, _ := os.ReadFile(filepath.Join(other, ManifestFileName)); string(data) != string(manifest) {
+        t.Errorf("manifest after rollback = %q, want %q", data, manifest)
+    }
+    if data, _ := os.ReadFile(getMetaFilePath("utils")); string(data) != string(meta) {
+        t.Errorf("metadata after rollback = %q, want %q", data, meta)
+    }
+}
+
+func TestModesAndSymlinks(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "run.sh"), "#!/bin/sh")
+    if err := os.Chmod(filepath.Join(project, "run.sh"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Symlink("run.sh", filepath.Join(project, "link")); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := AddToCollection("utils", []string{"run.sh", "link"}, false); err != nil {
+        t.Fatal(err)
+    }
+
+    other := filepath.Join(filepath.Dir(project), "other")
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+    if info, err := os.Stat(filepath.Join(other, "run.sh")); err != nil || info.Mode().Perm() != 0755 {
+        t.Fatalf("run.sh mode = %v, %v, want 0755", info.Mode().Perm(), err)
+    }
+    if link, err := os.Readlink(filepath.Join(other, "link")); err != nil || link != "run.sh" {
+        t.Fatalf("link = %q, %v, want a link to run.sh", link, err)
+    }
+
+    // A mode change alone is a local change
+    if err := os.Chmod(filepath.Join(other, "run.sh"), 0700); err != nil {
+        t.Fatal(err)
+    }
+    status, err := CheckForChanges("utils", other)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(status.LocalNewer) != 1 || filepath.Base(status.LocalNewer[0]) != "run.sh" {
+        t.Errorf("LocalNewer = %v, want run.sh", status.LocalNewer)
+    }
+}
+
+func TestUndoOperation(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "a.txt"), "one")
+    if _, err := AddToCollection("utils", []string{"a.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+
+    other := filepath.Join(filepath.Dir(project), "other")
+    writeFile(t, filepath.Join(other, "a.txt"), "local")
+    BeginOperation("pst require utils --force")
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+    if err := EndOperation(); err != nil {
+        t.Fatal(err)
+    }
+
+    // Changes made after the operation are only overwritten with force
+    writeFile(t, filepath.Join(other, "a.txt"), "later")
+    if _, _, err := UndoOperation("", false); err == nil {
+        t.Fatal("undo overwrote a file changed since the operation")
+    }
+    writeFile(t, filepath.Join(other, "a.txt"), "one")
+
+    operation, _, err := UndoOperation("", false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if data, _ := os.ReadFile(filepath.Join(other, "a.txt")); string(data) != "local" {
+        t.Errorf("a.txt = %q after undo, want %q", data, "local")
+    }
+    if _, err := os.Stat(filepath.Join(other, ManifestFileName)); !os.IsNotExist(err) {
+        t.Error("undo kept the manifest written by require")
+    }
+    if _, _, err := UndoOperation(operation.ID, false); err == nil {
+        t.Error("undid the same operation twice")
+    }
+}
+
+func TestTransferCollection(t *testing.T) {
+    project := setupProject(t)
+    if err := config.AddStore("team", filepath.Join(filepath.Dir(project), "team")); err != nil {
+        t.Fatal(err)
+    }
+    if err := IsValidCollectionName("other/utils"); err == nil {
+        t.Error("accepted a collection in an unknown store")
+    }
+
+    writeFile(t, filepath.Join(project, "a.txt"), "one")
+    if _, err := AddToCollection("utils", []string{"a.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RecordRevision("utils", "first"); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := TransferCollection("utils", "team/utils", false); err != nil {
+        t.Fatal(err)
+    }
+    if data, _ := os.ReadFile(filepath.Join(GetCollectionPath("team/utils"), "a.txt")); string(data) != "one" {
+        t.Errorf("published a.txt = %q, want %q", data, "one")
+    }
+
+    // A revision made in the team store must be fetched before publishing again
+    writeFile(t, filepath.Join(GetCollectionPath("team/utils"), "a.txt"), "two")
+    if _, err := RecordRevision("team/utils", "second"); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := TransferCollection("utils", "team/utils", false); err == nil {
+        t.Fatal("publishing dropped a revision of the team store")
+    }
+    if _, err := TransferCollection("team/utils", "utils", false); err != nil {
+        t.Fatal(err)
+    }
+    history, err := GetHistory("utils")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(history) != 2 || history[0].Message != "second" {
+        t.Errorf("fetched history has %d revisions, want the team revision on top", len(history))
+    }
+}
+
+func TestGitBackedCollection(t *testing.T) {
+    if _, err := exec.LookPath("git"); err != nil {
+        t.Skip("git is not installed")
+    }
+    project := setupProject(t)
+    remote := filepath.Join(filepath.Dir(project), "remote.git")
+    if _, err := runGit(filepath.Dir(project), "init", "-q", "--bare", remote); err != nil {
+        t.Fatal(err)
+    }
+    if err := EnableGit("utils", remote); err != nil {
+        t.Fatal(err)
+    }
+
+    writeFile(t, filepath.Join(project, "a.txt"), "one")
+    if _, err := AddToCollection("utils", []string{"a.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+    revision, err := RecordRevision("utils", "first")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if pushed, err := runGit(remote, "rev-parse", "main"); err != nil || pushed != revision.Commit {
+        t.Fatalf("remote main = %q, %v, want commit %q", pushed, err, revision.Commit)
+    }
+
+    history, err := GitLog("utils")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(history) != 1 || history[0].Message != "first" || len(history[0].Changes) != 1 || history[0].Changes[0].Action != ActionAdded {
+        t.Errorf("git log = %+v, want the first commit adding a.txt", history)
+    }
+    if found, err := LoadRevision("utils", revision.Commit[:8]); err != nil || found.ID != revision.ID {
+        t.Errorf("LoadRevision by commit = %v, %v, want revision %s", found, err, revision.ID)
+    }
+}
+
+func TestForceKeepsGitRepository(t *testing.T) {
+    if _, err := exec.LookPath("git"); err != nil {
+        t.Skip("git is not installed")
+    }
+    project := setupProject(t)
+    if err := EnableGit("utils", ""); err != nil {
+        t.Fatal(err)
+    }
+    writeFile(t, filepath.Join(project, "a.txt"), "one")
+    writeFile(t, filepath.Join(project, "b.txt"), "one")
+    if _, err := AddToCollection("utils", []string{"a.txt", "b.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RecordRevision("utils", "first"); err != nil {
+        t.Fatal(err)
+    }
+
+    // Rebuilding the collection replaces its files but not its repository
+    if _, err := AddToCollection("utils", []string{"a.txt"}, true); err != nil {
+        t.Fatal(err)
+    }
+    central := GetCollectionPath("utils")
+    if _, err := os.Stat(filepath.Join(central, ".git", "HEAD")); err != nil {
+        t.Fatalf("init --force deleted the git repository: %v", err)
+    }
+    if _, err := os.Stat(filepath.Join(central, "b.txt")); !os.IsNotExist(err) {
+        t.Error("b.txt is still in central after rebuilding without it")
+    }
+    if _, err := RecordRevision("utils", "second"); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestTemplateVariables(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "config.php"), "namespace {{ .Namespace }};\n")
+    writeFile(t, filepath.Join(project, "notes.txt"), "plain\n")
+    if _, err := AddToCollection("utils", []string{"config.php", "notes.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+
+    other := filepath.Join(filepath.Dir(project), "other")
+    if err := SetVariables("utils", other, map[string]string{"Namespace": `Acme\Utils`}); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+    if data, _ := os.ReadFile(filepath.Join(other, "config.php")); string(data) != "namespace Acme\\Utils;\n" {
+        t.Fatalf("config.php = %q, want the rendered namespace", data)
+    }
+    status, err := CheckForChanges("utils", other)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !status.InSync() {
+        t.Fatalf("status = %+v, want rendered files in sync", status)
+    }
+
+    // Pushed values go back to placeholders, but only in files that have them
+    writeFile(t, filepath.Join(other, "config.php"), "namespace Acme\\Utils;\nuse Acme\\Utils\\Helper;\n")
+    writeFile(t, filepath.Join(other, "notes.txt"), "Acme\\Utils\n")
+    if _, err := PushFiles("utils", other, []string{filepath.Join(other, "config.php"), filepath.Join(other, "notes.txt")}); err != nil {
+        t.Fatal(err)
+    }
+    central := GetCollectionPath("utils")
+    if data, _ := os.ReadFile(filepath.Join(central, "config.php")); string(data) != "namespace {{ .Namespace }};\nuse {{ .Namespace }}\\Helper;\n" {
+        t.Errorf("central config.php = %q, want placeholders", data)
+    }
+    if data, _ := os.ReadFile(filepath.Join(central, "notes.txt")); string(data) != "Acme\\Utils\n" {
+        t.Errorf("central notes.txt = %q, want the literal value", data)
+    }
+    if status, err := CheckForChanges("utils", other); err != nil || !status.InSync() {
+        t.Errorf("status after push = %+v, %v, want in sync", status, err)
+    }
+}
+
+func TestRewriteRules(t *testing.T) {
+    tests := []struct {
+        language, from, to, source, want string
+    }{
+        {"php", `Common\Utils`, `Acme\Helpers`,
+            "<?php\nnamespace Common\\Utils;\n\nuse Common\\Utils\\Str, \\Common\\Utils\\Arr;\nuse Common\\UtilsExtra;\n$x = 'Common\\Utils';\n",
+            "<?php\nnamespace Acme\\Helpers;\n\nuse Acme\\Helpers\\Str, \\Acme\\Helpers\\Arr;\nuse Common\\UtilsExtra;\n$x = 'Common\\Utils';\n"},
+        {"go", "example.com/common/utils", "github.com/acme/app/helpers",
+            "package utils\n\nimport (\n\t\"fmt\"\n\tsub \"example.com/common/utils/sub\"\n)\n\nvar s = \"example.com/common/utils\"\n",
+            "package helpers\n\nimport (\n\t\"fmt\"\n\tsub \"github.com/acme/app/helpers/sub\"\n)\n\nvar s = \"example.com/common/utils\"\n"},
+        {"js", "@common/utils", "@acme/helpers",
+            "import { a } from '@common/utils/a';\nconst b = require(\"@common/utils\");\nconst c = '@common/utils';\n",
+            "import { a } from '@acme/helpers/a';\nconst b = require(\"@acme/helpers\");\nconst c = '@common/utils';\n"},
+    }
+    for _, test := range tests {
+        got := string(rewriteSource(test.language, []byte(test.source), test.from, test.to))
+        if got != test.want {
+            t.Errorf("rewriteSource(%s) = %q, want %q", test.language, got, test.want)
+        }
+        if back := string(rewriteSource(test.language, []byte(got), test.to, test.from)); back != test.source {
+            t.Errorf("reversed rewriteSource(%s) = %q, want %q", test.language, back, test.source)
+        }
+    }
+
+    // Projects get their own namespace on require, and push the collection's back
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "utils.php"), "<?php\nnamespace Common\\Utils;\n")
+    if err := SetCollectionRewrites("utils", map[string]string{"php": `Common\Utils`}); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := AddToCollection("utils", []string{"utils.php"}, false); err != nil {
+        t.Fatal(err)
+    }
+    other := filepath.Join(filepath.Dir(project), "other")
+    if err := SetRewrites("utils", other, map[string]string{"php": `Acme\Helpers`}); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+    if data, _ := os.ReadFile(filepath.Join(other, "utils.php")); string(data) != "<?php\nnamespace Acme\\Helpers;\n" {
+        t.Fatalf("utils.php = %q, want the project namespace", data)
+    }
+    writeFile(t, filepath.Join(other, "utils.php"), "<?php\nnamespace Acme\\Helpers;\n\nfunction f() {}\n")
+    if _, err := PushFiles("utils", other, []string{filepath.Join(other, "utils.php")}); err != nil {
+        t.Fatal(err)
+    }
+    if data, _ := os.ReadFile(filepath.Join(GetCollectionPath("utils"), "utils.php")); string(data) != "<?php\nnamespace Common\\Utils;\n\nfunction f() {}\n" {
+        t.Errorf("central utils.php = %q, want the collection namespace", data)
+    }
+}
+
+func TestProvenanceHeader(t *testing.T) {
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "run.sh"), "#!/bin/sh\necho hi\n")
+    writeFile(t, filepath.Join(project, "data.json"), "{}\n")
+    if err := SetHeader("utils", true); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := AddToCollection("utils", []string{"run.sh", "data.json"}, false); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := RecordRevision("utils", "Initial"); err != nil {
+        t.Fatal(err)
+    }
+
+    other := filepath.Join(filepath.Dir(project), "other")
+    if _, err := RequireCollection("utils", other); err != nil {
+        t.Fatal(err)
+    }
+    head, err := LoadHead("utils")
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := "#!/bin/sh\n# Managed by pst: collection utils, revision " + head.ShortID() + ". Do not edit without running pst push.\necho hi\n"
+    if data, _ := os.ReadFile(filepath.Join(other, "run.sh")); string(data) != want {
+        t.Fatalf("run.sh = %q, want %q", data, want)
+    }
+    if data, _ := os.ReadFile(filepath.Join(other, "data.json")); string(data) != "{}\n" {
+        t.Errorf("data.json = %q, want no header for a format without comments", data)
+    }
+    if status, err := CheckForChanges("utils", other); err != nil || !status.InSync() {
+        t.Fatalf("status = %+v, %v, want the header ignored", status, err)
+    }
+
+    // Pushes leave the header out of central, and a newer revision doesn't make the header drift
+    edited := strings.Replace(want, "echo hi", "echo hello", 1)
+    writeFile(t, filepath.Join(other, "run.sh"), edited)
+    if _, err := PushFiles("utils", other, []string{filepath.Join(other, "run.sh")}); err != nil {
+        t.Fatal(err)
+    }
+    if data, _ := os.ReadFile(filepath.Join(GetCollectionPath("utils"), "run.sh")); string(data) != "#!/bin/sh\necho hello\n" {
+        t.Errorf("central run.sh = %q, want it without header", data)
+    }
+    if _, err := RecordRevision("utils", "Edit"); err != nil {
+        t.Fatal(err)
+    }
+    if status, err := CheckForChanges("utils", other); err != nil || !status.InSync() {
+        t.Errorf("status after a new revision = %+v, %v, want in sync", status, err)
+    }
+}
+
+func TestHooks(t *testing.T) {
+    if _, err := exec.LookPath("sh"); err != nil {
+        t.Skip("sh not available")
+    }
+    project := setupProject(t)
+    writeFile(t, filepath.Join(project, "a.txt"), "a\n")
+    if _, err := AddToCollection("utils", []string{"a.txt"}, false); err != nil {
+        t.Fatal(err)
+    }
+    if err := AddHook("utils", "", "pre-deploy", "true"); err == nil {
+        t.Error("AddHook accepted an unknown hook")
+    }
+
+    // Hooks of the configuration file run first, then those of the collection and the project, with the files on stdin and in the environment
+    log := filepath.Join(filepath.Dir(project), "hook.log")
+    if err := AddHook("utils", "", HookPostPush, `echo "$PST_HOOK $PST_COLLECTION" >> `+log); err != nil {
+        t.Fatal(err)
+    }
+    if err := AddHook("utils", project, HookPostPush, `cat >> `+log+` && echo "$PST_FILES" >> `+log); err != nil {
+        t.Fatal(err)
+    }
+    files, err := ResultFiles("utils", project, []FileResult{{Path: "a.txt", Action: "push"}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(files) != 1 || files[0] != filepath.Join(project, "a.txt") {
+        t.Fatalf("ResultFiles = %v, want a.txt in the project", files)
+    }
+    if err := config.Save(config.Config{Hooks: map[string][]string{HookPostPush: {"echo config >> " + log}}}); err != nil {
+        t.Fatal(err)
+    }
+    if err := RunHooks(HookPostPush, "utils", project, files); err != nil {
+        t.Fatal(err)
+    }
+    want := "config\npost-push utils\n" + files[0] + "\n" + files[0] + "\n"
+    if data, _ := os.ReadFile(log); string(data) != want {
+        t.Errorf("hook log = %q, want %q", data, want)
+    }
+
+    // A failing command is reported, and hooks are skipped when no files are concerned
+    if err := AddHook("utils", "", HookPrePush, "exit 1"); err != nil {
+        t.Fatal(err)
+    }
+    if err := RunHooks(HookPrePush, "utils", project, files); err == nil {
+        t.Error("RunHooks succeeded with a failing command")
+    }
+    if err := RunHooks(HookPrePush, "utils", project, nil); err != nil {
+        t.Errorf("RunHooks ran without files: %v", err)
+    }
+    if err := RemoveHook("utils", "", HookPrePush); err != nil {
+        t.Fatal(err)
+    }
+    if err := RunHooks(HookPrePush, "utils", project, files); err != nil {
+        t.Errorf("RunHooks after removal: %v", err)
+    }
+
+    // Hooks arriving with a committed manifest don't run until trusted
+    marker := filepath.Join(filepath.Dir(project), "untrusted")
+    err = updateManifestEntry("utils", project, func(entry *ManifestEntry) {
+        entry.Hooks[HookPreRequire] = []string{"touch " + marker}
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := RunHooks(HookPreRequire, "utils", project, files); err == nil {
+        t.Error("RunHooks ran an untrusted hook")
+    }
+    if _, err := os.Stat(marker); !os.IsNotExist(err) {
+        t.Fatal("untrusted hook ran")
+    }
+    if err := AddHook("utils", project, HookPreRequire, "true"); err != nil {
+        t.Fatal(err)
+    }
+    if err := RunHooks(HookPreRequire, "utils", project, files); err == nil {
+        t.Error("adding a hook trusted the hooks added by others")
+    }
+    if _, _, err := TrustHooks("utils", project); err != nil {
+        t.Fatal(err)
+    }
+    if err := RunHooks(HookPreRequire, "utils", project, files); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(marker); err != nil {
+        t.Errorf("trusted hook didn't run: %v", err)
+    }
+
+    // So do collection hooks written to the store by someone else
+    marker = filepath.Join(filepath.Dir(project), "untrusted-collection")
+    meta, err := requireCollectionMeta("utils")
+    if err != nil {
+        t.Fatal(err)
+    }
+    meta.Hooks[HookPreSync] = []string{"touch " + marker}
+    if err := writeCollectionMeta("utils", meta); err != nil {
+        t.Fatal(err)
+    }
+    if err := RunHooks(HookPreSync, "utils", project, files); err == nil {
+        t.Error("RunHooks ran an untrusted collection hook")
+    }
+    if _, err := os.Stat(marker); !os.IsNotExist(err) {
+        t.Fatal("untrusted collection hook ran")
+    }
+    collection, _, err := GetHooks("utils", project)
+    if err != nil || collection.Trusted {
+        t.Errorf("GetHooks = %+v, %v, want the collection hooks untrusted", collection, err)
+    }
+    if _, _, err := TrustHooks("utils", project); err != nil {
+        t.Fatal(err)
+    }
+    if err := RunHooks(HookPreSync, "utils", project, files); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(marker); err != nil {
+        t.Errorf("trusted collection hook didn't run: %v", err)
+    }
+}
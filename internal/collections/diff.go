@@ -0,0 +1,231 @@
+// internal/collections/diff.go
+
+package collections
+
+import (
+    "bytes"
+    "fmt"
+    "strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change in a unified diff.
+const diffContext = 3
+
+// diffOp is a single line of an edit script: ' ' keeps, '-' deletes and '+' inserts a line.
+type diffOp struct {
+    kind byte
+    line string
+}
+
+// IsBinary reports whether data looks like binary content, using the same NUL byte heuristic as git.
+func IsBinary(data []byte) bool {
+    if len(data) > 8000 {
+        data = data[:8000]
+    }
+    return bytes.IndexByte(data, 0) != -1
+}
+
+// splitLines splits content into lines, keeping the line terminators.
+func splitLines(data []byte) []string {
+    lines := strings.SplitAfter(string(data), "\n")
+    if lines[len(lines)-1] == "" {
+        lines = lines[:len(lines)-1]
+    }
+    return lines
+}
+
+// maxDiffWork bounds the work of diffLines, counted as lines times the number of edits searched for.
+// Files further apart than that are treated as replaced entirely, so comparing large unrelated files stays cheap.
+const maxDiffWork = 1 << 26
+
+// differ computes an edit script with the linear space variant of the Myers algorithm, splitting the
+// files at the middle of a shortest edit path and diffing both halves in turn.
+type differ struct {
+    a, b  []string
+    limit int // Most edits searched for before giving up on a shortest script
+    ops   []diffOp
+}
+
+// diffLines computes the shortest edit script turning a into b, or one replacing every line if the files
+// differ too much for that to be affordable.
+func diffLines(a, b []string) []diffOp {
+    d := &differ{a: a, b: b}
+    d.limit = max(maxDiffWork/(len(a)+len(b)+1), 1)
+    d.diff(0, len(a), 0, len(b))
+    return d.ops
+}
+
+// diff appends the edit script turning a[aLo:aHi] into b[bLo:bHi].
+func (d *differ) diff(aLo, aHi, bLo, bHi int) {
+    // Lines shared at both ends are kept without searching
+    for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+        d.ops = append(d.ops, diffOp{' ', d.a[aLo]})
+        aLo, bLo = aLo+1, bLo+1
+    }
+    suffix := 0
+    for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-suffix-1] == d.b[bHi-suffix-1] {
+        suffix++
+    }
+    aHi, bHi = aHi-suffix, bHi-suffix
+
+    if aLo == aHi || bLo == bHi {
+        d.replace(aLo, aHi, bLo, bHi)
+    } else if x, y, ok := d.split(aLo, aHi, bLo, bHi); ok {
+        d.diff(aLo, x, bLo, y)
+        d.diff(x, aHi, y, bHi)
+    } else {
+        d.replace(aLo, aHi, bLo, bHi)
+    }
+
+    for _, line := range d.a[aHi : aHi+suffix] {
+        d.ops = append(d.ops, diffOp{' ', line})
+    }
+}
+
+// replace appends an edit script deleting a[aLo:aHi] and inserting b[bLo:bHi].
+func (d *differ) replace(aLo, aHi, bLo, bHi int) {
+    for _, line := range d.a[aLo:aHi] {
+        d.ops = append(d.ops, diffOp{'-', line})
+    }
+    for _, line := range d.b[bLo:bHi] {
+        d.ops = append(d.ops, diffOp{'+', line})
+    }
+}
+
+// split finds a point in the middle of a shortest edit path from a[aLo:aHi] to b[bLo:bHi], searching
+// forwards from the start and backwards from the end until both searches meet. It reports false if
+// there is no common line, or if the path is longer than the limit. Only the furthest point reached on
+// each diagonal is kept, so memory stays linear in the length of the files.
+func (d *differ) split(aLo, aHi, bLo, bHi int) (int, int, bool) {
+    n, m := aHi-aLo, bHi-bLo
+    maxD := (n + m + 1) / 2
+    offset := maxD + 1
+    forward, backward := make([]int, 2*offset+1), make([]int, 2*offset+1)
+    for i := range forward {
+        forward[i], backward[i] = -1, -1
+    }
+    forward[offset+1], backward[offset+1] = 0, 0
+    delta := n - m
+    odd := delta%2 != 0
+
+    // Diagonals that ran off the edges of the grid are skipped from then on
+    fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+    for e := 0; e < maxD && e <= d.limit; e++ {
+        for k := -e + fStart; k <= e-fEnd; k += 2 {
+            var x int
+            if k == -e || (k != e && forward[offset+k-1] < forward[offset+k+1]) {
+                x = forward[offset+k+1]
+            } else {
+                x = forward[offset+k-1] + 1
+            }
+            y := x - k
+            for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+                x, y = x+1, y+1
+            }
+            forward[offset+k] = x
+            switch {
+            case x > n:
+                fEnd += 2
+            case y > m:
+                fStart += 2
+            case odd:
+                if i := offset + delta - k; i >= 0 && i < len(backward) && backward[i] != -1 && x >= n-backward[i] {
+                    return aLo + x, bLo + y, true
+                }
+            }
+        }
+
+        for k := -e + bStart; k <= e-bEnd; k += 2 {
+            var x int
+            if k == -e || (k != e && backward[offset+k-1] < backward[offset+k+1]) {
+                x = backward[offset+k+1]
+            } else {
+                x = backward[offset+k-1] + 1
+            }
+            y := x - k
+            for x < n && y < m && d.a[aHi-x-1] == d.b[bHi-y-1] {
+                x, y = x+1, y+1
+            }
+            backward[offset+k] = x
+            switch {
+            case x > n:
+                bEnd += 2
+            case y > m:
+                bStart += 2
+            case !odd:
+                if i := offset + delta - k; i >= 0 && i < len(forward) && forward[i] != -1 && forward[i] >= n-x {
+                    fx := forward[i]
+                    return aLo + fx, bLo + fx - (delta - k), true
+                }
+            }
+        }
+    }
+    return 0, 0, false
+}
+
+// UnifiedDiff returns a unified diff turning from into to, or an empty string if they are identical.
+func UnifiedDiff(fromName, toName string, from, to []byte) string {
+    if bytes.Equal(from, to) {
+        return ""
+    }
+
+    ops := diffLines(splitLines(from), splitLines(to))
+
+    // Record the line numbers in both files at the start of each op
+    fromLine := make([]int, len(ops)+1)
+    toLine := make([]int, len(ops)+1)
+    for i, op := range ops {
+        fromLine[i+1], toLine[i+1] = fromLine[i], toLine[i]
+        if op.kind != '+' {
+            fromLine[i+1]++
+        }
+        if op.kind != '-' {
+            toLine[i+1]++
+        }
+    }
+
+    var out strings.Builder
+    fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+    i := 0
+    for i < len(ops) {
+        // Skip to the next change
+        for i < len(ops) && ops[i].kind == ' ' {
+            i++
+        }
+        if i == len(ops) {
+            break
+        }
+
+        // Extend the hunk while changes are close enough to share context
+        start := max(i-diffContext, 0)
+        end := i + 1
+        for j := i; j < len(ops) && j-end <= 2*diffContext; j++ {
+            if ops[j].kind != ' ' {
+                end = j + 1
+            }
+        }
+        end = min(end+diffContext, len(ops))
+
+        fromStart, fromCount := fromLine[start]+1, fromLine[end]-fromLine[start]
+        toStart, toCount := toLine[start]+1, toLine[end]-toLine[start]
+        if fromCount == 0 {
+            fromStart--
+        }
+        if toCount == 0 {
+            toStart--
+        }
+        fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", fromStart, fromCount, toStart, toCount)
+
+        for _, op := range ops[start:end] {
+            out.WriteByte(op.kind)
+            out.WriteString(op.line)
+            if !strings.HasSuffix(op.line, "\n") {
+                out.WriteString("\n\\ No newline at end of file\n")
+            }
+        }
+        i = end
+    }
+
+    return out.String()
+}
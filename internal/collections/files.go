@@ -6,17 +6,36 @@ import (
     "os"
     "path/filepath"
     "fmt"
+    "strings"
 )
 
-// GetCollectionFiles returns a list of file paths in the central collection directory for a collection.
+// GetCollectionFiles returns a list of file paths in the central collection directory for a collection,
+// leaving out files matching the collection's ignore patterns.
 func GetCollectionFiles(collectionName string) ([]string, error) {
     collectionDir := GetCollectionPath(collectionName)
     files := []string{}
 
-    err := filepath.Walk(collectionDir, func(path string, info os.FileInfo, err error) error {
+    ignore, err := loadCentralIgnore(collectionName)
+    if err != nil {
+        return nil, err
+    }
+
+    err = filepath.Walk(collectionDir, func(path string, info os.FileInfo, err error) error {
         if err != nil {
             return err
         }
+
+        relPath, err := filepath.Rel(collectionDir, path)
+        if err != nil {
+            return err
+        }
+        if relPath != "." && ignore.Match(relPath, info.IsDir()) {
+            if info.IsDir() {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+
         if !info.IsDir() {
             files = append(files, path)
         }
@@ -29,3 +48,166 @@ func GetCollectionFiles(collectionName string) ([]string, error) {
     return files, nil
 }
 
+// FilePair links a file in the central collection with its counterpart in a project.
+type FilePair struct {
+    RelPath     string // Path relative to the collection root
+    CentralPath string
+    ProjectPath string
+
+    centralMode os.FileMode     // Mode of the central file when CentralPath is a stored blob of a pinned revision
+    projectRoot string          // Project directory that symbolic links are resolved against
+    symlinks    string          // Symlink policy of the collection
+    filters     []contentFilter // Conversions between central and project content, such as template variables
+    header      *provenance     // Header written at the top of installed files, nil if the collection has none
+}
+
+// centralState returns the checksum and mode of the central file, as installed in the project
+// but without a provenance header, which is never compared.
+func (p FilePair) centralState() (FileState, error) {
+    var render converter
+    if len(p.filters) > 0 && p.centralMode&os.ModeSymlink == 0 {
+        render = func(data []byte) ([]byte, error) {
+            return p.Render(data), nil
+        }
+    }
+    state, err := convertedState(p.CentralPath, render)
+    if err == nil && p.centralMode != 0 && state.Checksum != "" {
+        state.Mode = p.centralMode
+    }
+    return state, err
+}
+
+// projectState returns the checksum and mode of the project file, leaving out its provenance header.
+// A symbolic link that the collection follows is compared by the file it points to, as that is what gets pushed.
+func (p FilePair) projectState() (FileState, error) {
+    var strip converter
+    if p.header != nil {
+        strip = func(data []byte) ([]byte, error) {
+            return stripHeader(data), nil
+        }
+    }
+    state, err := convertedState(p.ProjectPath, strip)
+    if err != nil || state.Mode&os.ModeSymlink == 0 || p.symlinks == "" {
+        return state, err
+    }
+    // Links the policy refuses are compared as they are, leaving the error to pushes
+    linked, err := linkSource(p.ProjectPath, p.projectRoot, p.symlinks)
+    if err != nil || linked == "" || linked == p.ProjectPath {
+        return state, nil
+    }
+    return convertedState(linked, strip)
+}
+
+// GetFilePairs returns the central and project paths of every file in a collection,
+// applying the path mappings declared for the collection in the project manifest.
+// Files ignored by the collection or the project are left out.
+// For a project pinned to a version, the central side is the stored content of the pinned revision.
+func GetFilePairs(collectionName, projectPath string) ([]FilePair, error) {
+    pinned, err := pinnedRevision(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    collectionFiles := []string{}
+    if pinned == nil {
+        collectionFiles, err = GetCollectionFiles(collectionName)
+        if err != nil {
+            return nil, err
+        }
+    } else {
+        for _, relPath := range sortedKeys(pinned.Files) {
+            collectionFiles = append(collectionFiles, filepath.Join(GetCollectionPath(collectionName), filepath.FromSlash(relPath)))
+        }
+    }
+
+    mappings, err := loadMappings(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    ignore, err := loadIgnoreRules(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    policy, err := loadSymlinkPolicy(collectionName)
+    if err != nil {
+        return nil, err
+    }
+    filters, err := loadFilters(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    header, err := loadHeader(collectionName, pinned)
+    if err != nil {
+        return nil, err
+    }
+
+    pairs := []FilePair{}
+    for _, centralFilePath := range collectionFiles {
+        relPath, err := filepath.Rel(GetCollectionPath(collectionName), centralFilePath)
+        if err != nil {
+            return nil, fmt.Errorf("failed to calculate relative path: %w", err)
+        }
+
+        pair := newFilePair(collectionName, projectPath, relPath, mappings)
+        pair.projectRoot, pair.symlinks, pair.filters, pair.header = projectPath, policy, filters, header
+        if pinned != nil {
+            pair.CentralPath = getBlobPath(collectionName, pinned.Files[filepath.ToSlash(relPath)])
+            pair.centralMode = pinned.mode(filepath.ToSlash(relPath))
+        }
+        if !ignore.Ignored(pair.RelPath, pair.ProjectPath, false) {
+            pairs = append(pairs, pair)
+        }
+    }
+    return pairs, nil
+}
+
+// newFilePair builds the pair for a path relative to the collection root.
+func newFilePair(collectionName, projectPath, relPath string, mappings map[string]string) FilePair {
+    return FilePair{
+        RelPath:     relPath,
+        CentralPath: filepath.Join(GetCollectionPath(collectionName), relPath),
+        ProjectPath: filepath.Join(projectPath, mapPath(relPath, mappings)),
+    }
+}
+
+// mapPath returns where a path relative to the collection root is installed, relative to the project target.
+// The longest mapping matching the path or one of its parent directories wins; unmapped paths are kept as is.
+func mapPath(relPath string, mappings map[string]string) string {
+    best, to := "", ""
+    found := false
+    for from, target := range mappings {
+        from = filepath.Clean(filepath.FromSlash(from))
+        if from != "." && relPath != from && !strings.HasPrefix(relPath, from+string(filepath.Separator)) {
+            continue
+        }
+        if !found || len(from) > len(best) {
+            best, to, found = from, target, true
+        }
+    }
+    if !found {
+        return relPath
+    }
+
+    to = filepath.FromSlash(to)
+    if best == "." {
+        return filepath.Join(to, relPath)
+    }
+    return filepath.Join(to, strings.TrimPrefix(relPath, best))
+}
+
+// lookupPairs returns the tracked pairs of a collection keyed by their project path.
+func lookupPairs(collectionName, projectPath string) (map[string]FilePair, error) {
+    base, err := loadBase(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    pairs, err := getTrackedPairs(collectionName, projectPath, base)
+    if err != nil {
+        return nil, err
+    }
+
+    lookup := map[string]FilePair{}
+    for _, pair := range pairs {
+        lookup[filepath.Clean(pair.ProjectPath)] = pair
+    }
+    return lookup, nil
+}
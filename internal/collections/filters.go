@@ -0,0 +1,160 @@
+// internal/collections/filters.go
+
+package collections
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+)
+
+// contentFilter converts the content of text files between central storage and a project,
+// so a project can hold its own variant of a shared file without it counting as a change.
+type contentFilter interface {
+    // toProject converts central content into what the project holds.
+    toProject(relPath string, data []byte) []byte
+    // toCentral converts project content back into central content. central is the current
+    // content of the central file, or nil for files not yet in central.
+    toCentral(relPath string, data, central []byte) []byte
+}
+
+// converter transforms file content while it is copied between central storage and a project.
+type converter func(data []byte) ([]byte, error)
+
+// loadFilters returns the content filters that apply to a collection installed at projectPath.
+func loadFilters(collectionName, projectPath string) ([]contentFilter, error) {
+    entry, err := findManifestEntry(collectionName, projectPath)
+    if err != nil || entry == nil {
+        return nil, err
+    }
+
+    filters := []contentFilter{}
+    if len(entry.Variables) > 0 {
+        filters = append(filters, templateFilter{variables: entry.Variables})
+    }
+    if len(entry.Rewrites) > 0 {
+        meta, err := requireCollectionMeta(collectionName)
+        if err != nil {
+            return nil, fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+        }
+        if len(meta.Rewrites) > 0 {
+            filters = append(filters, rewriteFilter{central: meta.Rewrites, project: entry.Rewrites})
+        }
+    }
+    return filters, nil
+}
+
+// Render returns central content as it is installed in the project. Binary files are never converted.
+func (p FilePair) Render(data []byte) []byte {
+    if IsBinary(data) {
+        return data
+    }
+    for _, filter := range p.filters {
+        data = filter.toProject(p.RelPath, data)
+    }
+    return data
+}
+
+// StripHeader returns project content without the provenance header of the collection.
+func (p FilePair) StripHeader(data []byte) []byte {
+    if p.header == nil || IsBinary(data) {
+        return data
+    }
+    return stripHeader(data)
+}
+
+// withHeader returns project content with the provenance header of the collection at the top.
+func (p FilePair) withHeader(data []byte) []byte {
+    if p.header == nil {
+        return data
+    }
+    return p.header.add(p.RelPath, data)
+}
+
+// unrender returns project content as it is stored in central, without its provenance header
+// and applying the filters in reverse order.
+func (p FilePair) unrender(data []byte) ([]byte, error) {
+    data = p.StripHeader(data)
+    if len(p.filters) == 0 || IsBinary(data) {
+        return data, nil
+    }
+    var central []byte
+    if p.CentralPath != "" {
+        var err error
+        if central, err = os.ReadFile(p.CentralPath); err != nil && !os.IsNotExist(err) {
+            return nil, fmt.Errorf("failed to read central file %s: %w", p.CentralPath, err)
+        }
+    }
+    for i := len(p.filters) - 1; i >= 0; i-- {
+        data = p.filters[i].toCentral(p.RelPath, data, central)
+    }
+    return data, nil
+}
+
+// converter returns the conversion applied when copying the pair towards central or towards the project,
+// or nil if the content is copied as is.
+func (p FilePair) converter(toCentral bool) converter {
+    if len(p.filters) == 0 && p.header == nil {
+        return nil
+    }
+    if toCentral {
+        return p.unrender
+    }
+    return func(data []byte) ([]byte, error) {
+        return p.withHeader(p.Render(data)), nil
+    }
+}
+
+// chainConverters returns a converter applying first and then second, either of which may be nil.
+func chainConverters(first, second converter) converter {
+    if first == nil || second == nil {
+        if first == nil {
+            return second
+        }
+        return first
+    }
+    return func(data []byte) ([]byte, error) {
+        data, err := first(data)
+        if err != nil {
+            return nil, err
+        }
+        return second(data)
+    }
+}
+
+// convertedState returns the state a copy of path gets when its content passes through convert.
+// Symbolic links and missing files are never converted.
+func convertedState(path string, convert converter) (FileState, error) {
+    state, err := fileState(path)
+    if err != nil || convert == nil || state.Checksum == "" || state.Mode&os.ModeSymlink != 0 {
+        return state, err
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return FileState{}, err
+    }
+    converted, err := convert(data)
+    if err != nil {
+        return FileState{}, err
+    }
+    if !bytes.Equal(converted, data) {
+        state.Checksum = checksumData(converted)
+    }
+    return state, nil
+}
+
+// writeCentralBlob stores the central file as installed in the project in the object store,
+// so it can serve as the ancestor of later merges.
+func (p FilePair) writeCentralBlob(collectionName, checksum string) error {
+    if len(p.filters) == 0 || p.centralMode&os.ModeSymlink != 0 {
+        return writeBlob(collectionName, p.CentralPath, checksum)
+    }
+    if info, err := os.Lstat(p.CentralPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+        return writeBlob(collectionName, p.CentralPath, checksum)
+    }
+    data, err := os.ReadFile(p.CentralPath)
+    if err != nil {
+        return fmt.Errorf("failed to store blob for %s: %w", p.CentralPath, err)
+    }
+    return writeBlobData(collectionName, p.Render(data), checksum)
+}
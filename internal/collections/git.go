@@ -0,0 +1,252 @@
+// internal/collections/git.go
+
+package collections
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// runGit runs the system git binary in dir and returns its trimmed output.
+// Commits are attributed to the pst author unless git identities are set in the environment.
+func runGit(dir string, args ...string) (string, error) {
+    cmd := exec.Command("git", args...)
+    cmd.Dir = dir
+    cmd.Env = os.Environ()
+    for _, role := range []string{"AUTHOR", "COMMITTER"} {
+        if os.Getenv("GIT_"+role+"_NAME") == "" {
+            cmd.Env = append(cmd.Env, "GIT_"+role+"_NAME="+currentAuthor())
+        }
+        if os.Getenv("GIT_"+role+"_EMAIL") == "" {
+            cmd.Env = append(cmd.Env, "GIT_"+role+"_EMAIL="+currentAuthor()+"@pst")
+        }
+    }
+
+    var stdout, stderr bytes.Buffer
+    cmd.Stdout, cmd.Stderr = &stdout, &stderr
+    if err := cmd.Run(); err != nil {
+        return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+    }
+    return strings.TrimSpace(stdout.String()), nil
+}
+
+// IsGitBacked reports whether the central directory of a collection is a git repository managed by pst.
+func IsGitBacked(collectionName string) (bool, error) {
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return false, fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    return meta.Git, nil
+}
+
+// EnableGit makes the central directory of a collection a git repository, in which every recorded revision
+// becomes a commit. Commits are pushed to remote, such as a bare repository, if given.
+func EnableGit(collectionName, remote string) error {
+    collectionPath := GetCollectionPath(collectionName)
+    if err := os.MkdirAll(collectionPath, os.ModePerm); err != nil {
+        return fmt.Errorf("failed to create collection directory: %w", err)
+    }
+    if _, err := os.Stat(filepath.Join(collectionPath, ".git")); os.IsNotExist(err) {
+        if _, err := runGit(collectionPath, "init", "-q", "-b", "main"); err != nil {
+            return err
+        }
+    }
+    if remote != "" {
+        if _, err := runGit(collectionPath, "remote", "get-url", "origin"); err == nil {
+            _, err = runGit(collectionPath, "remote", "set-url", "origin", remote)
+            if err != nil {
+                return err
+            }
+        } else if _, err := runGit(collectionPath, "remote", "add", "origin", remote); err != nil {
+            return err
+        }
+    }
+
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    meta.Git = true
+    if remote != "" {
+        meta.GitRemote = remote
+    }
+    return writeCollectionMeta(collectionName, meta)
+}
+
+// commitGit commits the central files of a git-backed collection and pushes the commit to its remote.
+// It returns the commit hash, or an empty string if the collection isn't git-backed.
+func commitGit(collectionName, author, message string) (string, error) {
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil || !meta.Git {
+        return "", err
+    }
+
+    collectionPath := GetCollectionPath(collectionName)
+    if _, err := runGit(collectionPath, "add", "-A"); err != nil {
+        return "", err
+    }
+    // Every revision gets a commit, even if git sees no change, such as a mode change with core.fileMode off
+    if _, err := runGit(collectionPath, "commit", "-q", "--allow-empty", "--author", author+" <"+author+"@pst>", "-m", message); err != nil {
+        return "", err
+    }
+    commit, err := runGit(collectionPath, "rev-parse", "HEAD")
+    if err != nil {
+        return "", err
+    }
+    if meta.GitRemote != "" {
+        if _, err := runGit(collectionPath, "push", "-q", "origin", "HEAD"); err != nil {
+            return commit, err
+        }
+    }
+    return commit, nil
+}
+
+// tagGit tags the commit of a revision in a git-backed collection, pushing the tag to its remote.
+func tagGit(collectionName, tag string, revision *Revision) error {
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil || !meta.Git || revision.Commit == "" {
+        return err
+    }
+
+    collectionPath := GetCollectionPath(collectionName)
+    if _, err := runGit(collectionPath, "tag", "-f", tag, revision.Commit); err != nil {
+        return err
+    }
+    if meta.GitRemote != "" {
+        if _, err := runGit(collectionPath, "push", "-q", "-f", "origin", "refs/tags/"+tag); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// GitLog returns the commits of a git-backed collection as revisions, newest first.
+// The ID of each revision is its commit hash, which LoadRevision also accepts.
+func GitLog(collectionName string) ([]Revision, error) {
+    output, err := runGit(GetCollectionPath(collectionName), "log", "--format=%x1e%H%x00%an%x00%aI%x00%s", "--name-status")
+    if err != nil {
+        return nil, err
+    }
+
+    history := []Revision{}
+    for _, record := range strings.Split(output, "\x1e") {
+        lines := strings.Split(strings.TrimSpace(record), "\n")
+        fields := strings.SplitN(lines[0], "\x00", 4)
+        if len(fields) < 4 {
+            continue
+        }
+        revision := Revision{ID: fields[0], Commit: fields[0], Author: fields[1], Message: fields[3], Changes: []FileResult{}}
+        if revision.Time, err = time.Parse(time.RFC3339, fields[2]); err != nil {
+            return nil, fmt.Errorf("failed to parse time of commit %s: %w", fields[0], err)
+        }
+
+        for _, line := range lines[1:] {
+            status, path, found := strings.Cut(line, "\t")
+            if !found {
+                continue
+            }
+            action := ActionModified
+            switch status[0] {
+            case 'A':
+                action = ActionAdded
+            case 'D':
+                action = ActionDeleted
+            }
+            revision.Changes = append(revision.Changes, FileResult{Path: path, Action: action})
+        }
+        history = append(history, revision)
+    }
+    return history, nil
+}
+
+// ShowRevision returns a revision of a collection, the latest if ref is empty, with a patch of its changes.
+// For git-backed collections the patch is that of the commit, as shown by git.
+func ShowRevision(collectionName, ref string) (*Revision, string, error) {
+    var revision *Revision
+    var err error
+    if ref == "" {
+        revision, err = LoadHead(collectionName)
+        if err == nil && revision == nil {
+            err = fmt.Errorf("collection %s has no history", collectionName)
+        }
+    } else {
+        revision, err = LoadRevision(collectionName, ref)
+    }
+    if err != nil {
+        return nil, "", err
+    }
+
+    if revision.Commit != "" {
+        if git, err := IsGitBacked(collectionName); err != nil {
+            return nil, "", err
+        } else if git {
+            patch, err := runGit(GetCollectionPath(collectionName), "show", "--no-color", "--format=", "--patch", revision.Commit)
+            return revision, patch + "\n", err
+        }
+    }
+
+    parent := &Revision{}
+    if revision.Parent != "" {
+        if parent, err = loadRevisionFile(collectionName, revision.Parent); err != nil {
+            return nil, "", err
+        }
+    }
+    var patch strings.Builder
+    for _, change := range revision.Changes {
+        from, err := readRevisionFile(collectionName, parent, change.Path)
+        if err != nil {
+            return nil, "", err
+        }
+        to, err := readRevisionFile(collectionName, revision, change.Path)
+        if err != nil {
+            return nil, "", err
+        }
+        if IsBinary(from) || IsBinary(to) {
+            fmt.Fprintf(&patch, "Binary file %s %s\n", change.Path, change.Action)
+            continue
+        }
+        patch.WriteString(UnifiedDiff("a/"+change.Path, "b/"+change.Path, from, to))
+    }
+    return revision, patch.String(), nil
+}
+
+// readRevisionFile returns the content of a file in a revision, or nil if the revision doesn't have it.
+func readRevisionFile(collectionName string, revision *Revision, relPath string) ([]byte, error) {
+    checksum, ok := revision.Files[relPath]
+    if !ok {
+        return nil, nil
+    }
+    return readBlob(collectionName, checksum)
+}
+
+// RecordCommit stores in the project manifest the git commit of the central files installed in a project:
+// those of the given revision, the pinned revision or the latest commit. It does nothing for collections
+// that aren't git-backed.
+func RecordCommit(collectionName, projectPath, revisionID string) error {
+    if git, err := IsGitBacked(collectionName); err != nil || !git {
+        return err
+    }
+
+    commit := ""
+    revision, err := pinnedRevision(collectionName, projectPath)
+    if revisionID != "" {
+        revision, err = LoadRevision(collectionName, revisionID)
+    }
+    if err != nil {
+        return err
+    }
+    if revision != nil {
+        commit = revision.Commit
+    } else if commit, err = runGit(GetCollectionPath(collectionName), "rev-parse", "HEAD"); err != nil {
+        return err
+    }
+
+    return updateManifestEntry(collectionName, projectPath, func(entry *ManifestEntry) {
+        entry.Commit = commit
+    })
+}
@@ -0,0 +1,141 @@
+// internal/collections/header.go
+
+package collections
+
+import (
+    "fmt"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// headerComments maps file extensions to the opening and closing of a line comment in that language.
+// Files of other types, including formats without comments such as JSON, never get a header.
+var headerComments = map[string][2]string{
+    ".go":   {"// ", ""},
+    ".js":   {"// ", ""},
+    ".mjs":  {"// ", ""},
+    ".cjs":  {"// ", ""},
+    ".jsx":  {"// ", ""},
+    ".ts":   {"// ", ""},
+    ".tsx":  {"// ", ""},
+    ".php":  {"// ", ""},
+    ".java": {"// ", ""},
+    ".c":    {"// ", ""},
+    ".h":    {"// ", ""},
+    ".cpp":  {"// ", ""},
+    ".cs":   {"// ", ""},
+    ".rs":   {"// ", ""},
+    ".scss": {"// ", ""},
+    ".sh":   {"# ", ""},
+    ".bash": {"# ", ""},
+    ".py":   {"# ", ""},
+    ".rb":   {"# ", ""},
+    ".yml":  {"# ", ""},
+    ".yaml": {"# ", ""},
+    ".toml": {"# ", ""},
+    ".sql":  {"-- ", ""},
+    ".lua":  {"-- ", ""},
+    ".css":  {"/* ", " */"},
+    ".html": {"<!-- ", " -->"},
+    ".xml":  {"<!-- ", " -->"},
+    ".md":   {"<!-- ", " -->"},
+    ".vue":  {"<!-- ", " -->"},
+}
+
+// headerPattern matches a provenance header line, whatever its comment syntax.
+var headerPattern = regexp.MustCompile(`^[ \t]*(?://|#|--|/\*|<!--)\s*Managed by pst: collection \S+.*\n?`)
+
+// provenance describes where the files of a project come from, written at the top of each file as a header.
+type provenance struct {
+    collection string
+    revision   string // Short ID of the revision installed, empty for collections without history
+}
+
+// loadHeader returns the provenance header of a collection, or nil if it doesn't add one.
+// The revision is the given one, or else the latest revision of the collection.
+func loadHeader(collectionName string, revision *Revision) (*provenance, error) {
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    if !meta.Header {
+        return nil, nil
+    }
+    if revision == nil {
+        if revision, err = LoadHead(collectionName); err != nil {
+            return nil, err
+        }
+    }
+
+    header := &provenance{collection: collectionName}
+    if revision != nil {
+        header.revision = revision.ShortID()
+    }
+    return header, nil
+}
+
+// text returns the header line for a file, or an empty string if its type has no known comment syntax.
+func (h *provenance) text(relPath string) string {
+    comment, ok := headerComments[strings.ToLower(filepath.Ext(relPath))]
+    if !ok {
+        return ""
+    }
+    source := "collection " + h.collection
+    if h.revision != "" {
+        source += ", revision " + h.revision
+    }
+    return comment[0] + "Managed by pst: " + source + ". Do not edit without running pst push." + comment[1] + "\n"
+}
+
+// add inserts the header at the top of a text file, after a shebang line or the opening tag of a PHP file.
+// Files already starting with a header get the new one instead.
+func (h *provenance) add(relPath string, data []byte) []byte {
+    header := h.text(relPath)
+    if header == "" || IsBinary(data) {
+        return data
+    }
+    data = stripHeader(data)
+
+    content := string(data)
+    first, rest, found := strings.Cut(content, "\n")
+    switch {
+    case strings.ToLower(filepath.Ext(relPath)) == ".php":
+        // Outside the opening tag a comment would be printed as text
+        if !found || !strings.HasPrefix(first, "<?php") || strings.Contains(first, "?>") {
+            return data
+        }
+        return []byte(first + "\n" + header + rest)
+    case strings.HasPrefix(first, "#!"):
+        if !found {
+            return data
+        }
+        return []byte(first + "\n" + header + rest)
+    }
+    return []byte(header + content)
+}
+
+// stripHeader removes a provenance header from the top of a file, where add puts it.
+func stripHeader(data []byte) []byte {
+    content := string(data)
+    if loc := headerPattern.FindStringIndex(content); loc != nil {
+        return []byte(content[loc[1]:])
+    }
+    first, rest, found := strings.Cut(content, "\n")
+    if found && (strings.HasPrefix(first, "#!") || strings.HasPrefix(first, "<?php")) {
+        if loc := headerPattern.FindStringIndex(rest); loc != nil {
+            return []byte(first + "\n" + rest[loc[1]:])
+        }
+    }
+    return data
+}
+
+// SetHeader turns the provenance header of a collection on or off.
+func SetHeader(collectionName string, enabled bool) error {
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    meta.Header = enabled
+    return writeCollectionMeta(collectionName, meta)
+}
@@ -0,0 +1,488 @@
+// internal/collections/history.go
+
+package collections
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "os/user"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+
+    "gopkg.in/yaml.v3"
+)
+
+// Revision is an immutable snapshot of a central collection.
+type Revision struct {
+    ID      string                 `json:"id" yaml:"id"`
+    Parent  string                 `json:"parent,omitempty" yaml:"parent,omitempty"`
+    Commit  string                 `json:"commit,omitempty" yaml:"commit,omitempty"` // Git commit of git-backed collections
+    Author  string                 `json:"author" yaml:"author"`
+    Time    time.Time              `json:"time" yaml:"time"`
+    Message string                 `json:"message" yaml:"message"`
+    Changes []FileResult           `json:"changes" yaml:"changes"`
+    Files   map[string]string      `json:"-" yaml:"files,omitempty"` // Path relative to the collection -> blob checksum
+    Modes   map[string]os.FileMode `json:"-" yaml:"modes,omitempty"` // Path relative to the collection -> file mode
+}
+
+// mode returns the mode recorded for a file in the revision, defaulting to a regular file for older revisions.
+func (r Revision) mode(relPath string) os.FileMode {
+    if mode, ok := r.Modes[relPath]; ok {
+        return mode
+    }
+    return 0644
+}
+
+// ShortID returns the abbreviated revision ID used for display.
+func (r Revision) ShortID() string {
+    if len(r.ID) > 12 {
+        return r.ID[:12]
+    }
+    return r.ID
+}
+
+// getObjectsPath returns the directory holding content-addressed file blobs shared by all collections
+// in the store of a collection. An empty name refers to the default store.
+func getObjectsPath(collectionName string) string {
+    return getCollectionStorePath(collectionName, "objects")
+}
+
+// getHistoryPath returns the directory holding the revisions of a collection.
+func getHistoryPath(collectionName string) string {
+    _, name := splitCollectionName(collectionName)
+    return getCollectionStorePath(collectionName, "history", name)
+}
+
+// getBlobPath returns the path of the blob with the given checksum in the store of a collection.
+func getBlobPath(collectionName, checksum string) string {
+    return filepath.Join(getObjectsPath(collectionName), checksum[:2], checksum[2:])
+}
+
+// writeBlob stores a copy of a file in the object store of a collection under its checksum, if not already there.
+// The blob of a symbolic link holds the path it points to.
+func writeBlob(collectionName, path, checksum string) error {
+    blobPath := getBlobPath(collectionName, checksum)
+    if _, err := os.Stat(blobPath); err == nil {
+        return nil
+    }
+
+    var content io.Reader
+    if target, err := os.Readlink(path); err == nil {
+        content = strings.NewReader(target)
+    } else {
+        file, err := os.Open(path)
+        if err != nil {
+            return fmt.Errorf("failed to store blob for %s: %w", path, err)
+        }
+        defer file.Close()
+        content = file
+    }
+    if err := writeFileAtomic(blobPath, content); err != nil {
+        return fmt.Errorf("failed to store blob for %s: %w", path, err)
+    }
+    return os.Chmod(blobPath, 0444)
+}
+
+// writeBlobData stores content held in memory in the object store of a collection under its checksum, if not already there.
+func writeBlobData(collectionName string, data []byte, checksum string) error {
+    blobPath := getBlobPath(collectionName, checksum)
+    if _, err := os.Stat(blobPath); err == nil {
+        return nil
+    }
+    if err := writeFileAtomic(blobPath, bytes.NewReader(data)); err != nil {
+        return fmt.Errorf("failed to store blob %s: %w", checksum, err)
+    }
+    return os.Chmod(blobPath, 0444)
+}
+
+// readBlob returns the content of the blob with the given checksum in the store of a collection.
+func readBlob(collectionName, checksum string) ([]byte, error) {
+    data, err := os.ReadFile(getBlobPath(collectionName, checksum))
+    if err != nil {
+        return nil, fmt.Errorf("failed to read blob %s: %w", checksum, err)
+    }
+    return data, nil
+}
+
+// LoadHead returns the latest revision of a collection, or nil if it has no history.
+func LoadHead(collectionName string) (*Revision, error) {
+    data, err := os.ReadFile(filepath.Join(getHistoryPath(collectionName), "HEAD"))
+    if os.IsNotExist(err) {
+        return nil, nil
+    } else if err != nil {
+        return nil, fmt.Errorf("failed to read history of %s: %w", collectionName, err)
+    }
+    return loadRevisionFile(collectionName, strings.TrimSpace(string(data)))
+}
+
+// loadRevisionFile reads a revision by its full ID.
+func loadRevisionFile(collectionName, id string) (*Revision, error) {
+    data, err := os.ReadFile(filepath.Join(getHistoryPath(collectionName), id+".yml"))
+    if err != nil {
+        return nil, fmt.Errorf("failed to read revision %s of %s: %w", id, collectionName, err)
+    }
+
+    revision := &Revision{}
+    if err := yaml.Unmarshal(data, revision); err != nil {
+        return nil, fmt.Errorf("failed to unmarshal revision %s: %w", id, err)
+    }
+    return revision, nil
+}
+
+// LoadRevision returns the revision of a collection with the given version tag, or whose ID starts with the given prefix.
+func LoadRevision(collectionName, prefix string) (*Revision, error) {
+    tags, err := LoadTags(collectionName)
+    if err != nil {
+        return nil, err
+    }
+    if id, ok := tags[prefix]; ok {
+        return loadRevisionFile(collectionName, id)
+    }
+
+    entries, err := os.ReadDir(getHistoryPath(collectionName))
+    if os.IsNotExist(err) {
+        return nil, fmt.Errorf("collection %s has no history", collectionName)
+    } else if err != nil {
+        return nil, fmt.Errorf("failed to read history of %s: %w", collectionName, err)
+    }
+
+    matches := []string{}
+    for _, entry := range entries {
+        id := strings.TrimSuffix(entry.Name(), ".yml")
+        if id != entry.Name() && prefix != "" && strings.HasPrefix(id, prefix) {
+            matches = append(matches, id)
+        }
+    }
+
+    // Revisions of git-backed collections can also be named by their commit
+    if len(matches) == 0 && prefix != "" {
+        history, err := GetHistory(collectionName)
+        if err != nil {
+            return nil, err
+        }
+        for _, revision := range history {
+            if strings.HasPrefix(revision.Commit, prefix) {
+                matches = append(matches, revision.ID)
+            }
+        }
+    }
+
+    switch len(matches) {
+    case 0:
+        return nil, fmt.Errorf("revision %s not found in collection %s", prefix, collectionName)
+    case 1:
+        return loadRevisionFile(collectionName, matches[0])
+    }
+    return nil, fmt.Errorf("revision %s is ambiguous in collection %s", prefix, collectionName)
+}
+
+// GetHistory returns the revisions of a collection, newest first.
+func GetHistory(collectionName string) ([]Revision, error) {
+    history := []Revision{}
+    revision, err := LoadHead(collectionName)
+    for err == nil && revision != nil {
+        history = append(history, *revision)
+        if revision.Parent == "" {
+            break
+        }
+        revision, err = loadRevisionFile(collectionName, revision.Parent)
+    }
+    return history, err
+}
+
+// RecordRevision snapshots the current state of the central collection. It returns nil
+// without recording anything if the collection is unchanged since the latest revision.
+func RecordRevision(collectionName, message string) (*Revision, error) {
+    head, err := LoadHead(collectionName)
+    if err != nil {
+        return nil, err
+    }
+
+    // Store a blob for every file in the collection
+    collectionFiles, err := GetCollectionFiles(collectionName)
+    if err != nil {
+        return nil, err
+    }
+    files := map[string]string{}
+    modes := map[string]os.FileMode{}
+    for _, file := range collectionFiles {
+        relPath, err := filepath.Rel(GetCollectionPath(collectionName), file)
+        if err != nil {
+            return nil, fmt.Errorf("failed to calculate relative path: %w", err)
+        }
+        state, err := fileState(file)
+        if err != nil {
+            return nil, fmt.Errorf("failed to calculate checksum for central file %s: %w", file, err)
+        }
+        if err := writeBlob(collectionName, file, state.Checksum); err != nil {
+            return nil, err
+        }
+        files[filepath.ToSlash(relPath)] = state.Checksum
+        modes[filepath.ToSlash(relPath)] = state.Mode
+    }
+
+    revision := &Revision{
+        Author:  currentAuthor(),
+        Time:    time.Now().UTC().Truncate(time.Second),
+        Message: message,
+        Files:   files,
+        Modes:   modes,
+    }
+    previous := &Revision{}
+    if head != nil {
+        revision.Parent = head.ID
+        previous = head
+    }
+    revision.Changes = compareTrees(previous, revision)
+    if head != nil && len(revision.Changes) == 0 {
+        return nil, nil
+    }
+
+    // Git-backed collections get a commit for the revision. A failed push leaves the commit to record
+    commit, gitErr := commitGit(collectionName, revision.Author, message)
+    if gitErr != nil && commit == "" {
+        return nil, gitErr
+    }
+    revision.Commit = commit
+
+    // The ID is the checksum of the revision's content
+    data, err := yaml.Marshal(revision)
+    if err != nil {
+        return nil, fmt.Errorf("failed to marshal revision: %w", err)
+    }
+    revision.ID = fmt.Sprintf("%x", sha256.Sum256(data))
+    if data, err = yaml.Marshal(revision); err != nil {
+        return nil, fmt.Errorf("failed to marshal revision: %w", err)
+    }
+
+    historyPath := getHistoryPath(collectionName)
+    if err := os.MkdirAll(historyPath, os.ModePerm); err != nil {
+        return nil, fmt.Errorf("failed to create history directory: %w", err)
+    }
+    if err := writeFileAtomic(filepath.Join(historyPath, revision.ID+".yml"), bytes.NewReader(data)); err != nil {
+        return nil, fmt.Errorf("failed to write revision: %w", err)
+    }
+    if err := writeFileAtomic(filepath.Join(historyPath, "HEAD"), strings.NewReader(revision.ID+"\n")); err != nil {
+        return nil, fmt.Errorf("failed to update history head: %w", err)
+    }
+    if gitErr != nil {
+        return revision, fmt.Errorf("failed to push commit %s: %w", commit, gitErr)
+    }
+    return revision, nil
+}
+
+// compareTrees lists the files added, modified and deleted between the trees of two revisions.
+// A file whose mode changed counts as modified.
+func compareTrees(previous, current *Revision) []FileResult {
+    changes := []FileResult{}
+    for path, checksum := range current.Files {
+        if old, ok := previous.Files[path]; !ok {
+            changes = append(changes, FileResult{Path: path, Action: ActionAdded})
+        } else if old != checksum || previous.mode(path) != current.mode(path) {
+            changes = append(changes, FileResult{Path: path, Action: ActionModified})
+        }
+    }
+    for path := range previous.Files {
+        if _, ok := current.Files[path]; !ok {
+            changes = append(changes, FileResult{Path: path, Action: ActionDeleted})
+        }
+    }
+    sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+    return changes
+}
+
+// currentAuthor returns the name recorded as the author of new revisions.
+func currentAuthor() string {
+    if author := os.Getenv("PST_AUTHOR"); author != "" {
+        return author
+    }
+    if current, err := user.Current(); err == nil {
+        return current.Username
+    }
+    return os.Getenv("USER")
+}
+
+// ParseCollectionRef splits a "<collection>@<revision>" reference. The revision is empty if not given.
+func ParseCollectionRef(ref string) (string, string, error) {
+    name, revision, found := strings.Cut(ref, "@")
+    if found && revision == "" {
+        return "", "", errors.New("missing revision after @")
+    }
+    if err := IsValidCollectionName(name); err != nil {
+        return "", "", err
+    }
+    return name, revision, nil
+}
+
+// RequireRevision installs the files of an older revision of a collection into the target path.
+// The installed files become the project's base, so the newer central files show up as central changes.
+// Tracked files the revision doesn't have are deleted from the project, unless they have local changes.
+// Any version pin is removed, as the project follows the latest central files from then on.
+func RequireRevision(collectionName, revisionID, targetPath string) ([]FileResult, error) {
+    plan, err := PlanRequireRevision(collectionName, revisionID, targetPath)
+    if err != nil {
+        return nil, err
+    }
+    return plan.Apply()
+}
+
+// PlanRequireRevision plans installing the files of an older revision of a collection into the target path,
+// as done by RequireRevision.
+func PlanRequireRevision(collectionName, revisionID, targetPath string) (*Plan, error) {
+    revision, err := LoadRevision(collectionName, revisionID)
+    if err != nil {
+        return nil, err
+    }
+
+    targetPath, err = filepath.Abs(targetPath)
+    if err != nil {
+        return nil, fmt.Errorf("could not determine target path: %w", err)
+    }
+    mappings, err := loadMappings(collectionName, targetPath)
+    if err != nil {
+        return nil, err
+    }
+    ignore, err := loadIgnoreRules(collectionName, targetPath)
+    if err != nil {
+        return nil, err
+    }
+    base, err := loadBase(collectionName, targetPath)
+    if err != nil {
+        return nil, err
+    }
+    filters, err := loadFilters(collectionName, targetPath)
+    if err != nil {
+        return nil, err
+    }
+    header, err := loadHeader(collectionName, revision)
+    if err != nil {
+        return nil, err
+    }
+
+    plan := &Plan{Collection: collectionName, Project: targetPath}
+    for _, relPath := range sortedKeys(revision.Files) {
+        checksum := revision.Files[relPath]
+        pair := newFilePair(collectionName, targetPath, filepath.FromSlash(relPath), mappings)
+        pair.CentralPath, pair.centralMode = getBlobPath(collectionName, checksum), revision.mode(relPath)
+        pair.filters, pair.header = filters, header
+        if ignore.Ignored(pair.RelPath, pair.ProjectPath, false) {
+            continue
+        }
+        if err := plan.addConvertedCopy(pair.RelPath, pair.CentralPath, pair.ProjectPath, ActionCopied, pair.centralMode, pair.converter(false)); err != nil {
+            return nil, err
+        }
+
+        // The base is the revision as installed, which is what the project is compared against
+        state, err := pair.centralState()
+        if err != nil {
+            return nil, err
+        }
+        if state.Checksum != checksum {
+            if err := pair.writeCentralBlob(collectionName, state.Checksum); err != nil {
+                return nil, err
+            }
+        }
+        base[pair.RelPath] = state
+    }
+
+    // Tracked files the revision doesn't have are deleted, unless they were changed in the project
+    tracked := make([]string, 0, len(base))
+    for relPath := range base {
+        tracked = append(tracked, relPath)
+    }
+    sort.Strings(tracked)
+    for _, relPath := range tracked {
+        if _, ok := revision.Files[filepath.ToSlash(relPath)]; ok {
+            continue
+        }
+        pair := newFilePair(collectionName, targetPath, relPath, mappings)
+        pair.filters, pair.header = filters, header
+        if ignore.Ignored(pair.RelPath, pair.ProjectPath, false) {
+            continue
+        }
+        state, err := pair.projectState()
+        if err != nil {
+            return nil, fmt.Errorf("failed to calculate checksum for project file %s: %w", pair.ProjectPath, err)
+        }
+        if state.Checksum == "" {
+            delete(base, relPath)
+        } else if state.Checksum == base[relPath].Checksum {
+            plan.addDelete(pair.RelPath, pair.ProjectPath, targetPath)
+            delete(base, relPath)
+        }
+    }
+
+    plan.finish = func() error {
+        if err := registerProject(collectionName, targetPath); err != nil {
+            return err
+        }
+        return updateManifestEntry(collectionName, targetPath, func(entry *ManifestEntry) {
+            entry.Files = base
+            entry.Version = ""
+            entry.Revision = ""
+        })
+    }
+    return plan, nil
+}
+
+// CheckoutRevision restores the central collection to the state of an older revision
+// and records the result as a new revision.
+func CheckoutRevision(collectionName, revisionID string) (*Revision, error) {
+    revision, err := LoadRevision(collectionName, revisionID)
+    if err != nil {
+        return nil, err
+    }
+
+    plan, err := planRevisionFiles(collectionName, revision)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := plan.Apply(); err != nil {
+        return nil, err
+    }
+
+    return RecordRevision(collectionName, fmt.Sprintf("Checkout %s", revision.ShortID()))
+}
+
+// planRevisionFiles plans replacing the central files of a collection with the files of a revision,
+// whose blobs must be in the store of the collection.
+func planRevisionFiles(collectionName string, revision *Revision) (*Plan, error) {
+    collectionPath := GetCollectionPath(collectionName)
+    collectionFiles, err := GetCollectionFiles(collectionName)
+    if err != nil {
+        return nil, err
+    }
+    plan := &Plan{Collection: collectionName}
+    for _, file := range collectionFiles {
+        relPath, err := filepath.Rel(collectionPath, file)
+        if err != nil {
+            return nil, fmt.Errorf("failed to calculate relative path: %w", err)
+        }
+        if _, ok := revision.Files[filepath.ToSlash(relPath)]; !ok {
+            plan.addDelete(relPath, file, collectionPath)
+        }
+    }
+    for _, relPath := range sortedKeys(revision.Files) {
+        centralPath := filepath.Join(collectionPath, filepath.FromSlash(relPath))
+        if err := plan.addCopy(relPath, getBlobPath(collectionName, revision.Files[relPath]), centralPath, ActionCopied, revision.mode(relPath)); err != nil {
+            return nil, err
+        }
+    }
+    return plan, nil
+}
+
+// sortedKeys returns the keys of a map in sorted order.
+func sortedKeys(m map[string]string) []string {
+    keys := make([]string, 0, len(m))
+    for key := range m {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
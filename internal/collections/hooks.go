@@ -0,0 +1,392 @@
+// internal/collections/hooks.go
+
+package collections
+
+import (
+    "bytes"
+    "fmt"
+    "gopkg.in/yaml.v3"
+    "io"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/forsvunnet/project-sync-tool/internal/config"
+)
+
+// Hooks run around the commands that change files. A failing pre hook aborts the command.
+const (
+    HookPreRequire  = "pre-require"
+    HookPostRequire = "post-require"
+    HookPrePush     = "pre-push"
+    HookPostPush    = "post-push"
+    HookPreSync     = "pre-sync"
+    HookPostSync    = "post-sync"
+)
+
+// HookNames lists the hooks that can be configured.
+var HookNames = []string{HookPreRequire, HookPostRequire, HookPrePush, HookPostPush, HookPreSync, HookPostSync}
+
+// HookOutput receives what hooks print to standard output.
+var HookOutput io.Writer = os.Stdout
+
+// RunHooks runs the commands configured for a hook, those of the configuration file first, then those of the
+// collection and then those of the project, passing the project files concerned. Each command runs with sh in the
+// project directory, gets the files one per line on standard input and in PST_FILES, and the hook, collection,
+// project and central directory in PST_HOOK, PST_COLLECTION, PST_PROJECT and PST_CENTRAL. Hooks don't run when
+// no files are concerned.
+func RunHooks(hook, collectionName, projectPath string, files []string) error {
+    if len(files) == 0 {
+        return nil
+    }
+    commands, err := hookCommands(hook, collectionName, projectPath)
+    if err != nil || len(commands) == 0 {
+        return err
+    }
+
+    // A project about to be required for the first time may not exist yet
+    dir := projectPath
+    if _, err := os.Stat(dir); err != nil {
+        if dir, err = os.Getwd(); err != nil {
+            return fmt.Errorf("could not determine working directory: %w", err)
+        }
+    }
+    list := strings.Join(files, "\n")
+    for _, command := range commands {
+        cmd := exec.Command("sh", "-c", command)
+        cmd.Dir = dir
+        cmd.Env = append(os.Environ(),
+            "PST_HOOK="+hook,
+            "PST_COLLECTION="+collectionName,
+            "PST_PROJECT="+projectPath,
+            "PST_CENTRAL="+GetCollectionPath(collectionName),
+            "PST_FILES="+list,
+        )
+        cmd.Stdin = strings.NewReader(list + "\n")
+        cmd.Stdout, cmd.Stderr = HookOutput, os.Stderr
+        if err := cmd.Run(); err != nil {
+            return fmt.Errorf("%s hook %q failed: %w", hook, command, err)
+        }
+    }
+    return nil
+}
+
+// hookCommands returns the commands configured for a hook by the configuration file, the collection and the
+// project at projectPath, in that order. Collection hooks come from a store that may be shared with others, and
+// project hooks from a manifest that may have been committed by anyone, so both only run once trusted.
+func hookCommands(hook, collectionName, projectPath string) ([]string, error) {
+    settings, err := config.Load()
+    if err != nil {
+        return nil, err
+    }
+    commands := append([]string{}, settings.Hooks[hook]...)
+
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    if len(meta.Hooks[hook]) > 0 {
+        trusted, err := hooksTrusted(collectionName, GetCollectionPath(collectionName), meta.Hooks)
+        if err != nil {
+            return nil, err
+        }
+        if !trusted {
+            return nil, fmt.Errorf("the hooks of collection %s are not trusted. Review them with pst hook list and run pst hook trust %s", collectionName, collectionName)
+        }
+        commands = append(commands, meta.Hooks[hook]...)
+    }
+
+    entry, err := findManifestEntry(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    if entry != nil && len(entry.Hooks[hook]) > 0 {
+        trusted, err := hooksTrusted(collectionName, projectPath, entry.Hooks)
+        if err != nil {
+            return nil, err
+        }
+        if !trusted {
+            return nil, fmt.Errorf("the hooks of collection %s in %s are not trusted. Review them with pst hook list and run pst hook trust %s", collectionName, projectPath, collectionName)
+        }
+        commands = append(commands, entry.Hooks[hook]...)
+    }
+    return commands, nil
+}
+
+// trustedHooksPath returns the file recording the hooks the user approved, kept with the
+// configuration file rather than in a project or store others can write to.
+func trustedHooksPath() string {
+    return filepath.Join(config.Dir(), "trusted-hooks.yml")
+}
+
+// loadTrustedHooks returns the checksums of the approved hooks, keyed by the directory they apply to and the
+// collection. Hooks of a collection are keyed by its central directory, those of a project by the project path.
+func loadTrustedHooks() (map[string]map[string]string, error) {
+    trusted := map[string]map[string]string{}
+    data, err := os.ReadFile(trustedHooksPath())
+    if os.IsNotExist(err) {
+        return trusted, nil
+    } else if err != nil {
+        return nil, fmt.Errorf("failed to read trusted hooks: %w", err)
+    }
+    if err := yaml.Unmarshal(data, &trusted); err != nil {
+        return nil, fmt.Errorf("failed to parse trusted hooks %s: %w", trustedHooksPath(), err)
+    }
+    if trusted == nil {
+        trusted = map[string]map[string]string{}
+    }
+    return trusted, nil
+}
+
+// hooksChecksum identifies a set of hooks, so a change to any command withdraws the approval.
+func hooksChecksum(hooks map[string][]string) (string, error) {
+    data, err := yaml.Marshal(hooks)
+    if err != nil {
+        return "", fmt.Errorf("failed to marshal hooks: %w", err)
+    }
+    return checksumData(data), nil
+}
+
+// hooksTrusted reports whether the user approved exactly these hooks for the collection in dir, its central
+// directory or a project path.
+func hooksTrusted(collectionName, dir string, hooks map[string][]string) (bool, error) {
+    trusted, err := loadTrustedHooks()
+    if err != nil {
+        return false, err
+    }
+    checksum, err := hooksChecksum(hooks)
+    if err != nil {
+        return false, err
+    }
+    return trusted[dir][collectionName] == checksum, nil
+}
+
+// setTrustedHooks records hooks as approved for the collection in dir, its central directory or a project path,
+// or withdraws the approval when there are none.
+func setTrustedHooks(collectionName, dir string, hooks map[string][]string) error {
+    trusted, err := loadTrustedHooks()
+    if err != nil {
+        return err
+    }
+    if len(hooks) == 0 {
+        delete(trusted[dir], collectionName)
+        if len(trusted[dir]) == 0 {
+            delete(trusted, dir)
+        }
+    } else {
+        checksum, err := hooksChecksum(hooks)
+        if err != nil {
+            return err
+        }
+        if trusted[dir] == nil {
+            trusted[dir] = map[string]string{}
+        }
+        trusted[dir][collectionName] = checksum
+    }
+
+    data, err := yaml.Marshal(trusted)
+    if err != nil {
+        return fmt.Errorf("failed to marshal trusted hooks: %w", err)
+    }
+    if err := os.MkdirAll(config.Dir(), os.ModePerm); err != nil {
+        return fmt.Errorf("failed to create configuration directory: %w", err)
+    }
+    return writeFileAtomic(trustedHooksPath(), bytes.NewReader(data))
+}
+
+// TrustHooks approves the hooks of the collection and those the manifest of the project at projectPath sets
+// for it, as they are now, and returns them. They run from then on, until they change.
+func TrustHooks(collectionName, projectPath string) (map[string][]string, map[string][]string, error) {
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    entry, err := findManifestEntry(collectionName, projectPath)
+    if err != nil {
+        return nil, nil, err
+    }
+    project := map[string][]string{}
+    if entry != nil && len(entry.Hooks) > 0 {
+        project = entry.Hooks
+    }
+    if len(meta.Hooks) == 0 && len(project) == 0 {
+        return nil, nil, fmt.Errorf("collection %s has no hooks in %s", collectionName, projectPath)
+    }
+
+    if len(meta.Hooks) > 0 {
+        if err := setTrustedHooks(collectionName, GetCollectionPath(collectionName), meta.Hooks); err != nil {
+            return nil, nil, err
+        }
+    }
+    if len(project) > 0 {
+        if err := setTrustedHooks(collectionName, projectPath, project); err != nil {
+            return nil, nil, err
+        }
+    }
+    return meta.Hooks, project, nil
+}
+
+// ResultFiles returns the project files of the results of a command, whose paths are relative to the collection.
+func ResultFiles(collectionName, projectPath string, results []FileResult) ([]string, error) {
+    pairs, err := lookupPairs(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    mappings, err := loadMappings(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    byRelPath := map[string]string{}
+    for _, pair := range pairs {
+        byRelPath[pair.RelPath] = pair.ProjectPath
+    }
+
+    files := []string{}
+    seen := map[string]bool{}
+    for _, result := range results {
+        file, ok := byRelPath[filepath.Clean(result.Path)]
+        if !ok {
+            // Files no longer tracked, such as deletions pushed to central, are where the collection would put them
+            file = newFilePair(collectionName, projectPath, filepath.Clean(result.Path), mappings).ProjectPath
+        }
+        if !seen[file] {
+            seen[file] = true
+            files = append(files, file)
+        }
+    }
+    sort.Strings(files)
+    return files, nil
+}
+
+// checkHook returns an error unless hook is one of HookNames.
+func checkHook(hook string) error {
+    for _, name := range HookNames {
+        if hook == name {
+            return nil
+        }
+    }
+    return fmt.Errorf("unknown hook %q: use %s", hook, strings.Join(HookNames, ", "))
+}
+
+// AddHook adds a command to a hook of the collection, or of the project at projectPath if it isn't empty.
+func AddHook(collectionName, projectPath, hook, command string) error {
+    if err := checkHook(hook); err != nil {
+        return err
+    }
+    update := func(hooks map[string][]string) {
+        hooks[hook] = append(hooks[hook], command)
+    }
+    if projectPath != "" {
+        return updateProjectHooks(collectionName, projectPath, update)
+    }
+    return updateCollectionHooks(collectionName, update)
+}
+
+// RemoveHook removes every command of a hook of the collection, or of the project at projectPath if it isn't empty.
+func RemoveHook(collectionName, projectPath, hook string) error {
+    if err := checkHook(hook); err != nil {
+        return err
+    }
+    update := func(hooks map[string][]string) {
+        delete(hooks, hook)
+    }
+    if projectPath != "" {
+        return updateProjectHooks(collectionName, projectPath, update)
+    }
+    return updateCollectionHooks(collectionName, update)
+}
+
+// updateCollectionHooks changes the hooks in the collection metadata. Like project hooks, they stay trusted
+// if they were before, or if the collection had none.
+func updateCollectionHooks(collectionName string, update func(hooks map[string][]string)) error {
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    central := GetCollectionPath(collectionName)
+    trusted := true
+    if len(meta.Hooks) > 0 {
+        if trusted, err = hooksTrusted(collectionName, central, meta.Hooks); err != nil {
+            return err
+        }
+    }
+
+    if meta.Hooks == nil {
+        meta.Hooks = map[string][]string{}
+    }
+    update(meta.Hooks)
+    if len(meta.Hooks) == 0 {
+        meta.Hooks = nil
+    }
+    if err := writeCollectionMeta(collectionName, meta); err != nil || !trusted {
+        return err
+    }
+    return setTrustedHooks(collectionName, central, meta.Hooks)
+}
+
+// updateProjectHooks changes the hooks in the manifest of the project at projectPath. Hooks the user changes
+// stay trusted if they were before, or if the project had none: a change can't approve commands added by others.
+func updateProjectHooks(collectionName, projectPath string, update func(hooks map[string][]string)) error {
+    entry, err := findManifestEntry(collectionName, projectPath)
+    if err != nil {
+        return err
+    }
+    trusted := true
+    if entry != nil && len(entry.Hooks) > 0 {
+        if trusted, err = hooksTrusted(collectionName, projectPath, entry.Hooks); err != nil {
+            return err
+        }
+    }
+
+    var hooks map[string][]string
+    err = updateManifestEntry(collectionName, projectPath, func(entry *ManifestEntry) {
+        if entry.Hooks == nil {
+            entry.Hooks = map[string][]string{}
+        }
+        update(entry.Hooks)
+        if len(entry.Hooks) == 0 {
+            entry.Hooks = nil
+        }
+        hooks = entry.Hooks
+    })
+    if err != nil || !trusted {
+        return err
+    }
+    return setTrustedHooks(collectionName, projectPath, hooks)
+}
+
+// HookSet is the commands of every hook configured in one place, and whether they are trusted to run.
+type HookSet struct {
+    Hooks   map[string][]string
+    Trusted bool
+}
+
+// GetHooks returns the hooks configured by the collection and by the project at projectPath.
+func GetHooks(collectionName, projectPath string) (HookSet, HookSet, error) {
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return HookSet{}, HookSet{}, fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    entry, err := findManifestEntry(collectionName, projectPath)
+    if err != nil {
+        return HookSet{}, HookSet{}, err
+    }
+
+    collection := HookSet{Hooks: map[string][]string{}, Trusted: true}
+    if len(meta.Hooks) > 0 {
+        collection.Hooks = meta.Hooks
+        if collection.Trusted, err = hooksTrusted(collectionName, GetCollectionPath(collectionName), meta.Hooks); err != nil {
+            return HookSet{}, HookSet{}, err
+        }
+    }
+    project := HookSet{Hooks: map[string][]string{}, Trusted: true}
+    if entry != nil && len(entry.Hooks) > 0 {
+        project.Hooks = entry.Hooks
+        if project.Trusted, err = hooksTrusted(collectionName, projectPath, entry.Hooks); err != nil {
+            return HookSet{}, HookSet{}, err
+        }
+    }
+    return collection, project, nil
+}
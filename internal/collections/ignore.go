@@ -0,0 +1,256 @@
+// internal/collections/ignore.go
+
+package collections
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "slices"
+    "strings"
+
+    "github.com/forsvunnet/project-sync-tool/internal/config"
+)
+
+// IgnoreFileName is the name of the file listing ignore patterns in a project root.
+const IgnoreFileName = ".pstignore"
+
+// defaultIgnorePatterns are always ignored: version control data and pst's own project files.
+var defaultIgnorePatterns = []string{".git/", ManifestFileName, IgnoreFileName}
+
+// ignorePattern is a single compiled gitignore-style pattern.
+type ignorePattern struct {
+    re      *regexp.Regexp
+    negate  bool
+    dirOnly bool
+}
+
+// ignoreMatcher matches paths against an ordered list of gitignore-style patterns.
+type ignoreMatcher []ignorePattern
+
+// newIgnoreMatcher compiles gitignore-style patterns, skipping blank lines and comments.
+func newIgnoreMatcher(lines []string) (ignoreMatcher, error) {
+    matcher := ignoreMatcher{}
+    for _, line := range lines {
+        line = strings.TrimRight(line, " \t\r")
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+
+        pattern := ignorePattern{}
+        if strings.HasPrefix(line, "!") {
+            pattern.negate = true
+            line = line[1:]
+        }
+        if strings.HasSuffix(line, "/") {
+            pattern.dirOnly = true
+            line = strings.TrimSuffix(line, "/")
+        }
+
+        // Patterns with a slash are relative to the root, others match at any depth
+        anchored := strings.Contains(line, "/")
+        line = strings.TrimPrefix(line, "/")
+        expr := globToRegexp(line)
+        if anchored {
+            expr = "^" + expr + "$"
+        } else {
+            expr = "^(.*/)?" + expr + "$"
+        }
+
+        re, err := regexp.Compile(expr)
+        if err != nil {
+            return nil, fmt.Errorf("invalid ignore pattern %q: %w", line, err)
+        }
+        pattern.re = re
+        matcher = append(matcher, pattern)
+    }
+    return matcher, nil
+}
+
+// globToRegexp converts a gitignore glob into a regular expression.
+func globToRegexp(glob string) string {
+    var expr strings.Builder
+    for i := 0; i < len(glob); i++ {
+        switch c := glob[i]; {
+        case strings.HasPrefix(glob[i:], "**/"):
+            expr.WriteString("(.*/)?")
+            i += 2
+        case strings.HasPrefix(glob[i:], "**"):
+            expr.WriteString(".*")
+            i++
+        case c == '*':
+            expr.WriteString("[^/]*")
+        case c == '?':
+            expr.WriteString("[^/]")
+        case c == '[':
+            end := strings.IndexByte(glob[i:], ']')
+            if end == -1 {
+                expr.WriteString(regexp.QuoteMeta(string(c)))
+                continue
+            }
+            class := glob[i+1 : i+end]
+            if strings.HasPrefix(class, "!") {
+                class = "^" + class[1:]
+            }
+            expr.WriteString("[" + class + "]")
+            i += end
+        case c == '\\' && i+1 < len(glob):
+            i++
+            expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+        default:
+            expr.WriteString(regexp.QuoteMeta(string(c)))
+        }
+    }
+    return expr.String()
+}
+
+// Match reports whether a slash-separated relative path, or any of its parent directories, is ignored.
+func (m ignoreMatcher) Match(relPath string, isDir bool) bool {
+    parts := strings.Split(filepath.ToSlash(relPath), "/")
+    for i := 1; i <= len(parts); i++ {
+        if m.matchPath(strings.Join(parts[:i], "/"), i < len(parts) || isDir) {
+            return true
+        }
+    }
+    return false
+}
+
+// matchPath applies the patterns to a single path; the last matching pattern wins.
+func (m ignoreMatcher) matchPath(path string, isDir bool) bool {
+    ignored := false
+    for _, pattern := range m {
+        if pattern.dirOnly && !isDir {
+            continue
+        }
+        if pattern.re.MatchString(path) {
+            ignored = !pattern.negate
+        }
+    }
+    return ignored
+}
+
+// ignoreRules combines the ignore patterns of a collection with those of a project.
+type ignoreRules struct {
+    central     ignoreMatcher // Matched against paths relative to the collection root
+    project     ignoreMatcher // Matched against paths relative to the project root
+    projectRoot string
+}
+
+// loadCentralIgnore returns the matcher for the default patterns and those stored in the collection metadata.
+func loadCentralIgnore(collectionName string) (ignoreMatcher, error) {
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    settings, err := config.Load()
+    if err != nil {
+        return nil, err
+    }
+    patterns := append(append([]string{}, defaultIgnorePatterns...), settings.Ignore...)
+    return newIgnoreMatcher(append(patterns, meta.Ignore...))
+}
+
+// loadIgnoreRules returns the ignore rules for a collection installed at projectPath.
+func loadIgnoreRules(collectionName, projectPath string) (ignoreRules, error) {
+    central, err := loadCentralIgnore(collectionName)
+    if err != nil {
+        return ignoreRules{}, err
+    }
+
+    projectPath, err = filepath.Abs(projectPath)
+    if err != nil {
+        return ignoreRules{}, fmt.Errorf("could not determine project path: %w", err)
+    }
+    root, err := projectRootFor(collectionName, projectPath)
+    if err != nil {
+        return ignoreRules{}, err
+    }
+
+    lines, err := readIgnoreFile(filepath.Join(root, IgnoreFileName))
+    if err != nil {
+        return ignoreRules{}, err
+    }
+    project, err := newIgnoreMatcher(lines)
+    if err != nil {
+        return ignoreRules{}, fmt.Errorf("failed to parse %s: %w", IgnoreFileName, err)
+    }
+
+    return ignoreRules{central: central, project: project, projectRoot: root}, nil
+}
+
+// Ignored reports whether a file is excluded, given its path relative to the collection and its path in the project.
+func (r ignoreRules) Ignored(relPath, projectFilePath string, isDir bool) bool {
+    if r.central.Match(relPath, isDir) {
+        return true
+    }
+    projectRelPath, err := filepath.Rel(r.projectRoot, projectFilePath)
+    if err != nil || !isWithin(projectFilePath, r.projectRoot) {
+        return false
+    }
+    return r.project.Match(projectRelPath, isDir)
+}
+
+// readIgnoreFile returns the lines of an ignore file, or nothing if it doesn't exist.
+func readIgnoreFile(path string) ([]string, error) {
+    file, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return nil, nil
+    } else if err != nil {
+        return nil, fmt.Errorf("failed to open %s: %w", path, err)
+    }
+    defer file.Close()
+
+    lines := []string{}
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        lines = append(lines, scanner.Text())
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("failed to read %s: %w", path, err)
+    }
+    return lines, nil
+}
+
+// AddIgnorePatterns stores extra ignore patterns in the collection metadata.
+func AddIgnorePatterns(collectionName string, patterns []string) error {
+    if _, err := newIgnoreMatcher(patterns); err != nil {
+        return err
+    }
+
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    for _, pattern := range patterns {
+        if !slices.Contains(meta.Ignore, pattern) {
+            meta.Ignore = append(meta.Ignore, pattern)
+        }
+    }
+    return writeCollectionMeta(collectionName, meta)
+}
+
+// SetIgnorePatterns replaces the ignore patterns stored in the collection metadata, so a collection
+// rebuilt with `init --force` doesn't keep the patterns of its previous version.
+func SetIgnorePatterns(collectionName string, patterns []string) error {
+    if _, err := newIgnoreMatcher(patterns); err != nil {
+        return err
+    }
+
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    ignore := []string{}
+    for _, pattern := range patterns {
+        if !slices.Contains(ignore, pattern) {
+            ignore = append(ignore, pattern)
+        }
+    }
+    if slices.Equal(meta.Ignore, ignore) {
+        return nil
+    }
+    meta.Ignore = ignore
+    return writeCollectionMeta(collectionName, meta)
+}
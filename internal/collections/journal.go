@@ -0,0 +1,304 @@
+// internal/collections/journal.go
+
+package collections
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+
+    "gopkg.in/yaml.v3"
+)
+
+// journalLimit is the number of operations kept in the journal.
+const journalLimit = 100
+
+// Operation is a journal entry recording every file a pst command wrote or deleted,
+// with the content each file had before so the command can be undone.
+type Operation struct {
+    ID      string        `json:"id" yaml:"id"`
+    Command string        `json:"command" yaml:"command"`
+    Dir     string        `json:"dir" yaml:"dir"` // Working directory the command ran in
+    Time    time.Time     `json:"time" yaml:"time"`
+    Files   []JournalFile `json:"files,omitempty" yaml:"files"`
+    Undone  *time.Time    `json:"undone,omitempty" yaml:"undone,omitempty"`
+}
+
+// JournalFile is a file changed by an operation. An empty checksum means the file didn't exist.
+type JournalFile struct {
+    Path   string    `json:"path" yaml:"path"`
+    Before FileState `json:"before" yaml:"before"`
+    After  FileState `json:"after" yaml:"after"`
+}
+
+// currentOperation collects the files changed by the running command, nil when not journaling.
+var currentOperation *Operation
+
+// getJournalPath returns the directory holding the operation journal.
+func getJournalPath() string {
+    return getStorePath("journal")
+}
+
+// BeginOperation starts journaling the files changed by a command.
+func BeginOperation(command string) {
+    dir, _ := os.Getwd()
+    now := time.Now().UTC()
+    currentOperation = &Operation{
+        ID:      now.Format("20060102T150405.000000000"),
+        Command: command,
+        Dir:     dir,
+        Time:    now,
+    }
+}
+
+// EndOperation stops journaling and saves the operation if it changed any files.
+// Files left as they were before the command are dropped from the entry.
+func EndOperation() error {
+    operation := currentOperation
+    currentOperation = nil
+    if operation == nil {
+        return nil
+    }
+
+    files := []JournalFile{}
+    for _, file := range operation.Files {
+        after, err := fileState(file.Path)
+        if err != nil {
+            return fmt.Errorf("failed to record %s in the journal: %w", file.Path, err)
+        }
+        if after != file.Before {
+            file.After = after
+            files = append(files, file)
+        }
+    }
+    if len(files) == 0 {
+        return nil
+    }
+    operation.Files = files
+
+    if err := saveOperation(operation); err != nil {
+        return err
+    }
+    return pruneJournal()
+}
+
+// journalFile records the content of path before the running command changes it. Only the first
+// change counts, and files in the object store or the journal, which are never overwritten, are left out.
+func journalFile(path string) error {
+    if currentOperation == nil || isObjectPath(path) || isWithin(path, getJournalPath()) {
+        return nil
+    }
+    path, err := filepath.Abs(path)
+    if err != nil {
+        return err
+    }
+    for _, file := range currentOperation.Files {
+        if file.Path == path {
+            return nil
+        }
+    }
+
+    before, err := fileState(path)
+    if err != nil {
+        return fmt.Errorf("failed to record %s in the journal: %w", path, err)
+    }
+    if before.Checksum != "" {
+        if err := writeBlob("", path, before.Checksum); err != nil {
+            return err
+        }
+    }
+    currentOperation.Files = append(currentOperation.Files, JournalFile{Path: path, Before: before})
+    return nil
+}
+
+// journalTree records every file under root before the running command deletes it.
+func journalTree(root string) error {
+    if currentOperation == nil {
+        return nil
+    }
+    return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+        if os.IsNotExist(err) {
+            return nil
+        } else if err != nil {
+            return err
+        }
+        if info.IsDir() {
+            return nil
+        }
+        return journalFile(path)
+    })
+}
+
+// saveOperation writes an operation to the journal.
+func saveOperation(operation *Operation) error {
+    if err := os.MkdirAll(getJournalPath(), os.ModePerm); err != nil {
+        return fmt.Errorf("failed to create journal directory: %w", err)
+    }
+    data, err := yaml.Marshal(operation)
+    if err != nil {
+        return fmt.Errorf("failed to marshal operation: %w", err)
+    }
+    if err := writeFileAtomic(filepath.Join(getJournalPath(), operation.ID+".yml"), bytes.NewReader(data)); err != nil {
+        return fmt.Errorf("failed to save operation %s: %w", operation.ID, err)
+    }
+    return nil
+}
+
+// pruneJournal deletes the oldest operations beyond the journal limit.
+func pruneJournal() error {
+    operations, err := GetOperations()
+    if err != nil {
+        return err
+    }
+    for _, operation := range operations[min(len(operations), journalLimit):] {
+        if err := os.Remove(filepath.Join(getJournalPath(), operation.ID+".yml")); err != nil && !os.IsNotExist(err) {
+            return fmt.Errorf("failed to prune operation %s: %w", operation.ID, err)
+        }
+    }
+    return nil
+}
+
+// GetOperations returns the journaled operations, newest first.
+func GetOperations() ([]Operation, error) {
+    entries, err := os.ReadDir(getJournalPath())
+    if os.IsNotExist(err) {
+        return []Operation{}, nil
+    } else if err != nil {
+        return nil, fmt.Errorf("failed to read journal: %w", err)
+    }
+
+    operations := []Operation{}
+    for _, entry := range entries {
+        if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yml") {
+            continue
+        }
+        operation, err := loadOperation(filepath.Join(getJournalPath(), entry.Name()))
+        if err != nil {
+            return nil, err
+        }
+        operations = append(operations, operation)
+    }
+    sort.Slice(operations, func(i, j int) bool {
+        return operations[i].ID > operations[j].ID
+    })
+    return operations, nil
+}
+
+// loadOperation reads an operation from a journal file.
+func loadOperation(path string) (Operation, error) {
+    operation := Operation{}
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return operation, fmt.Errorf("failed to read operation: %w", err)
+    }
+    if err := yaml.Unmarshal(data, &operation); err != nil {
+        return operation, fmt.Errorf("failed to unmarshal operation %s: %w", path, err)
+    }
+    return operation, nil
+}
+
+// findOperation returns the operation with the given ID or unique ID prefix, or the latest
+// operation not yet undone if the ID is empty.
+func findOperation(id string) (Operation, error) {
+    operations, err := GetOperations()
+    if err != nil {
+        return Operation{}, err
+    }
+
+    matches := []Operation{}
+    for _, operation := range operations {
+        if id == "" && operation.Undone == nil {
+            return operation, nil
+        }
+        if id != "" && strings.HasPrefix(operation.ID, id) {
+            matches = append(matches, operation)
+        }
+    }
+    switch {
+    case id == "":
+        return Operation{}, fmt.Errorf("no operation to undo")
+    case len(matches) == 0:
+        return Operation{}, fmt.Errorf("operation %s not found", id)
+    case len(matches) > 1:
+        return Operation{}, fmt.Errorf("operation ID %s is ambiguous", id)
+    }
+    return matches[0], nil
+}
+
+// UndoOperation restores every file changed by an operation to its content before the operation.
+// The latest operation not yet undone is used if id is empty. Files changed since the operation are
+// only restored with force, so later work isn't lost by accident.
+func UndoOperation(id string, force bool) (Operation, []FileResult, error) {
+    operation, err := findOperation(id)
+    if err != nil {
+        return operation, []FileResult{}, err
+    }
+    if operation.Undone != nil {
+        return operation, []FileResult{}, fmt.Errorf("operation %s was already undone", operation.ID)
+    }
+
+    if !force {
+        changed := []string{}
+        for _, file := range operation.Files {
+            current, err := fileState(file.Path)
+            if err != nil {
+                return operation, []FileResult{}, fmt.Errorf("failed to calculate checksum for %s: %w", file.Path, err)
+            }
+            if current != file.After {
+                changed = append(changed, file.Path)
+            }
+        }
+        if len(changed) > 0 {
+            return operation, []FileResult{}, fmt.Errorf("files changed since operation %s, use --force to restore them anyway: %s", operation.ID, strings.Join(changed, ", "))
+        }
+    }
+
+    tx := &transaction{}
+    results := []FileResult{}
+    for i := len(operation.Files) - 1; i >= 0; i-- {
+        file := operation.Files[i]
+        if file.Before.Checksum == "" {
+            if _, err := os.Lstat(file.Path); os.IsNotExist(err) {
+                continue
+            }
+            tx.stageDelete(file.Path)
+            results = append(results, FileResult{Path: file.Path, Action: ActionDeleted})
+            continue
+        }
+
+        mode := file.Before.Mode
+        if mode == 0 {
+            mode = 0644
+        }
+        if err := tx.stageCopy(getBlobPath("", file.Before.Checksum), file.Path, mode); err != nil {
+            tx.rollback()
+            return operation, []FileResult{}, err
+        }
+        results = append(results, FileResult{Path: file.Path, Action: ActionRestored})
+    }
+    if err := tx.commit(); err != nil {
+        return operation, []FileResult{}, err
+    }
+    tx.cleanup()
+
+    // Directories left empty by removed files are cleaned up, in the project or in central storage
+    for _, file := range operation.Files {
+        root, ok := storeRootOf(file.Path)
+        if !ok {
+            root = operation.Dir
+        }
+        if file.Before.Checksum == "" {
+            if err := removeEmptyParents(file.Path, root); err != nil {
+                return operation, results, err
+            }
+        }
+    }
+
+    now := time.Now().UTC()
+    operation.Undone = &now
+    return operation, results, saveOperation(&operation)
+}
@@ -0,0 +1,257 @@
+// internal/collections/manifest.go
+
+package collections
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// ManifestFileName is the name of the manifest kept in the root of every project.
+const ManifestFileName = ".pst.yml"
+
+// Manifest lists the collections a project requires. It lives in the project root so it can be
+// committed with the code, letting a fresh clone restore its collections with `pst require`.
+type Manifest struct {
+    Collections map[string]*ManifestEntry `yaml:"collections"`
+}
+
+// ManifestEntry describes where a collection is installed in the project and its state at the last sync.
+type ManifestEntry struct {
+    Target     string               `yaml:"target"`               // Directory relative to the project root
+    Mappings   map[string]string    `yaml:"mappings,omitempty"`   // Collection path -> path relative to the target
+    Files      map[string]FileState `yaml:"files,omitempty"`      // Base snapshot keyed by path relative to the collection
+    Version    string               `yaml:"version,omitempty"`    // Version constraint the project is pinned to
+    Revision   string               `yaml:"revision,omitempty"`   // Revision installed for the pinned version
+    Unresolved []string             `yaml:"unresolved,omitempty"` // Files left with merge conflicts, relative to the collection
+    Commit     string               `yaml:"commit,omitempty"`     // Git commit of the installed files, for git-backed collections
+    Variables  map[string]string    `yaml:"variables,omitempty"`  // Values of the placeholders in central files
+    Rewrites   map[string]string    `yaml:"rewrites,omitempty"`   // Language -> namespace, package or module path used instead of the collection's
+    Hooks      map[string][]string  `yaml:"hooks,omitempty"`      // Hook -> commands run around require, push and sync in this project only
+}
+
+// FindProjectRoot returns the nearest directory at or above dir that contains a manifest.
+func FindProjectRoot(dir string) (string, bool) {
+    dir, err := filepath.Abs(dir)
+    if err != nil {
+        return "", false
+    }
+
+    for {
+        if _, err := os.Stat(filepath.Join(dir, ManifestFileName)); err == nil {
+            return dir, true
+        }
+        parent := filepath.Dir(dir)
+        if parent == dir {
+            return "", false
+        }
+        dir = parent
+    }
+}
+
+// LoadManifest reads the manifest in the project root, returning an empty manifest if there is none.
+func LoadManifest(root string) (Manifest, error) {
+    manifest := Manifest{Collections: map[string]*ManifestEntry{}}
+
+    data, err := os.ReadFile(filepath.Join(root, ManifestFileName))
+    if os.IsNotExist(err) {
+        return manifest, nil
+    } else if err != nil {
+        return manifest, fmt.Errorf("failed to read manifest: %w", err)
+    }
+
+    if err := yaml.Unmarshal(data, &manifest); err != nil {
+        return manifest, fmt.Errorf("failed to unmarshal manifest: %w", err)
+    }
+    if manifest.Collections == nil {
+        manifest.Collections = map[string]*ManifestEntry{}
+    }
+    return manifest, nil
+}
+
+// saveManifest writes the manifest to the project root.
+func saveManifest(root string, manifest Manifest) error {
+    data, err := yaml.Marshal(&manifest)
+    if err != nil {
+        return fmt.Errorf("failed to marshal manifest: %w", err)
+    }
+    return writeFileAtomic(filepath.Join(root, ManifestFileName), bytes.NewReader(data))
+}
+
+// Names returns the names of the collections in the manifest in sorted order.
+func (m Manifest) Names() []string {
+    names := []string{}
+    for name := range m.Collections {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// projectRootFor returns the root of the project a collection is installed into at projectPath: the
+// directory whose manifest already declares the collection there, otherwise the nearest directory with a
+// manifest up to the working directory, or the working directory itself, if it contains projectPath,
+// otherwise projectPath itself. Manifests above the working directory belong to other projects, such as one
+// this project is nested in, and are left alone unless they declare the collection at projectPath.
+func projectRootFor(collectionName, projectPath string) (string, error) {
+    for dir := projectPath; ; dir = filepath.Dir(dir) {
+        if _, err := os.Stat(filepath.Join(dir, ManifestFileName)); err == nil {
+            manifest, err := LoadManifest(dir)
+            if err != nil {
+                return "", err
+            }
+            if entry := manifest.Collections[collectionName]; entry != nil && filepath.Join(dir, entry.Target) == projectPath {
+                return dir, nil
+            }
+        }
+        if filepath.Dir(dir) == dir {
+            break
+        }
+    }
+
+    cwd, err := os.Getwd()
+    if err != nil {
+        return "", fmt.Errorf("could not determine working directory: %w", err)
+    }
+    if !isWithin(projectPath, cwd) {
+        return projectPath, nil
+    }
+    for dir := projectPath; dir != cwd; dir = filepath.Dir(dir) {
+        if _, err := os.Stat(filepath.Join(dir, ManifestFileName)); err == nil {
+            return dir, nil
+        }
+    }
+    return cwd, nil
+}
+
+// ResolveProjectPath returns the directory a collection is installed into for the project containing dir,
+// as declared by the project manifest. Without a manifest entry the collection is installed into dir.
+func ResolveProjectPath(collectionName, dir string) (string, error) {
+    dir, err := filepath.Abs(dir)
+    if err != nil {
+        return "", fmt.Errorf("could not determine project path: %w", err)
+    }
+
+    root, ok := FindProjectRoot(dir)
+    if !ok {
+        return dir, nil
+    }
+    manifest, err := LoadManifest(root)
+    if err != nil {
+        return "", err
+    }
+    if entry := manifest.Collections[collectionName]; entry != nil {
+        return filepath.Join(root, entry.Target), nil
+    }
+    return dir, nil
+}
+
+// findManifestEntry returns the manifest entry installing the collection at projectPath, or nil if there is none.
+func findManifestEntry(collectionName, projectPath string) (*ManifestEntry, error) {
+    projectPath, err := filepath.Abs(projectPath)
+    if err != nil {
+        return nil, fmt.Errorf("could not determine project path: %w", err)
+    }
+
+    root, err := projectRootFor(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    manifest, err := LoadManifest(root)
+    if err != nil {
+        return nil, err
+    }
+
+    entry := manifest.Collections[collectionName]
+    if entry == nil || filepath.Join(root, entry.Target) != projectPath {
+        return nil, nil
+    }
+    return entry, nil
+}
+
+// updateManifestEntry applies update to the manifest entry installing the collection at projectPath,
+// creating the entry and the manifest if needed.
+func updateManifestEntry(collectionName, projectPath string, update func(entry *ManifestEntry)) error {
+    projectPath, err := filepath.Abs(projectPath)
+    if err != nil {
+        return fmt.Errorf("could not determine project path: %w", err)
+    }
+
+    root, err := projectRootFor(collectionName, projectPath)
+    if err != nil {
+        return err
+    }
+    manifest, err := LoadManifest(root)
+    if err != nil {
+        return err
+    }
+
+    entry := manifest.Collections[collectionName]
+    if entry == nil || filepath.Join(root, entry.Target) != projectPath {
+        target, err := filepath.Rel(root, projectPath)
+        if err != nil {
+            return fmt.Errorf("failed to calculate target path: %w", err)
+        }
+        entry = &ManifestEntry{Target: filepath.ToSlash(target)}
+        manifest.Collections[collectionName] = entry
+    }
+    update(entry)
+
+    if err := saveManifest(root, manifest); err != nil {
+        return fmt.Errorf("failed to save manifest: %w", err)
+    }
+    return nil
+}
+
+// loadMappings returns the path mappings declared for the collection installed at projectPath,
+// cleaned as SetMappings does, since the manifest may have been written by hand.
+func loadMappings(collectionName, projectPath string) (map[string]string, error) {
+    entry, err := findManifestEntry(collectionName, projectPath)
+    if err != nil || entry == nil || len(entry.Mappings) == 0 {
+        return nil, err
+    }
+    mappings, err := cleanMappings(entry.Mappings)
+    if err != nil {
+        return nil, fmt.Errorf("%s of %s: %w", ManifestFileName, projectPath, err)
+    }
+    return mappings, nil
+}
+
+// SetMappings declares where paths in a collection are installed in the project, relative to projectPath.
+// Each mapping takes a file or folder in the collection to a file or folder in the project.
+func SetMappings(collectionName, projectPath string, mappings map[string]string) error {
+    cleaned, err := cleanMappings(mappings)
+    if err != nil {
+        return err
+    }
+
+    return updateManifestEntry(collectionName, projectPath, func(entry *ManifestEntry) {
+        entry.Mappings = cleaned
+    })
+}
+
+// cleanMappings returns mappings with both sides cleaned, so src/ and ./src name the same folder as src.
+// Both sides must be relative and stay inside the collection and the target respectively.
+func cleanMappings(mappings map[string]string) (map[string]string, error) {
+    cleaned := map[string]string{}
+    for from, to := range mappings {
+        from, to = filepath.Clean(filepath.FromSlash(from)), filepath.Clean(filepath.FromSlash(to))
+        if filepath.IsAbs(from) || filepath.IsAbs(to) || !isWithin(from, ".") || !isWithin(to, ".") {
+            return nil, fmt.Errorf("invalid mapping %s=%s: paths must be relative and stay inside the collection and the target", from, to)
+        }
+        cleaned[filepath.ToSlash(from)] = filepath.ToSlash(to)
+    }
+    return cleaned, nil
+}
+
+// isWithin reports whether path is dir or inside it.
+func isWithin(path, dir string) bool {
+    rel, err := filepath.Rel(dir, path)
+    return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
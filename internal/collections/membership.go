@@ -0,0 +1,202 @@
+// internal/collections/membership.go
+
+package collections
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// AddPaths copies more files or folders into an existing collection and returns the files added.
+// Files already in the collection are only replaced when force is set; otherwise push should be used.
+func AddPaths(collectionName string, paths []string, force bool) ([]FileResult, error) {
+    collectionPath := GetCollectionPath(collectionName)
+    if _, err := os.Stat(collectionPath); os.IsNotExist(err) {
+        return nil, fmt.Errorf("collection %s does not exist, use init to create it", collectionName)
+    }
+
+    if !force {
+        projectRoot, err := os.Getwd()
+        if err != nil {
+            return nil, fmt.Errorf("could not determine working directory: %w", err)
+        }
+        for _, path := range paths {
+            _, relPath, err := addedPath(path, projectRoot)
+            if err != nil {
+                return nil, err
+            }
+            existing, err := findExistingFiles(path, filepath.Join(collectionPath, relPath))
+            if err != nil {
+                return nil, err
+            }
+            if len(existing) > 0 {
+                return nil, fmt.Errorf("%s is already in collection %s. Use push to update it or --force to replace it", existing[0], collectionName)
+            }
+        }
+    }
+
+    plan, err := PlanAddToCollection(collectionName, paths, false)
+    if err != nil {
+        return nil, err
+    }
+    return plan.Apply()
+}
+
+// findExistingFiles returns the files under srcPath that already exist under destPath.
+func findExistingFiles(srcPath, destPath string) ([]string, error) {
+    existing := []string{}
+    err := filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if info.IsDir() {
+            return nil
+        }
+
+        relPath, err := filepath.Rel(srcPath, path)
+        if err != nil {
+            return err
+        }
+        if _, err := os.Stat(filepath.Join(destPath, relPath)); err == nil {
+            existing = append(existing, filepath.Join(srcPath, relPath))
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("could not access path %s: %w", srcPath, err)
+    }
+    return existing, nil
+}
+
+// RemoveFromCollection deletes a file or folder from central storage and stops tracking it in every project.
+// When deleteCopies is set, unmodified copies are deleted from the projects as well; modified copies are kept
+// and reported as conflicts. It returns one result for central and one for each registered project.
+func RemoveFromCollection(collectionName, path string, deleteCopies bool) ([]Result, error) {
+    collectionPath := GetCollectionPath(collectionName)
+    relPath := filepath.Clean(path)
+    centralPath := filepath.Join(collectionPath, relPath)
+    if relPath == "." || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+        return nil, fmt.Errorf("%s is not a path inside collection %s", path, collectionName)
+    }
+    if _, err := os.Stat(centralPath); os.IsNotExist(err) {
+        return nil, fmt.Errorf("%s is not in collection %s", path, collectionName)
+    }
+
+    // Collect the central files being removed before deleting them
+    collectionFiles, err := GetCollectionFiles(collectionName)
+    if err != nil {
+        return nil, err
+    }
+    removed := []string{}
+    for _, file := range collectionFiles {
+        fileRelPath, err := filepath.Rel(collectionPath, file)
+        if err != nil {
+            return nil, fmt.Errorf("failed to calculate relative path: %w", err)
+        }
+        if fileRelPath == relPath || strings.HasPrefix(fileRelPath, relPath+string(filepath.Separator)) {
+            removed = append(removed, fileRelPath)
+        }
+    }
+
+    if err := journalTree(centralPath); err != nil {
+        return nil, err
+    }
+    if err := os.RemoveAll(centralPath); err != nil {
+        return nil, fmt.Errorf("failed to remove %s from collection: %w", relPath, err)
+    }
+    if err := removeFile(centralPath, collectionPath); err != nil {
+        return nil, fmt.Errorf("failed to clean up directories for %s: %w", relPath, err)
+    }
+
+    central := Result{Collection: collectionName, Action: "remove"}
+    for _, file := range removed {
+        central.Files = append(central.Files, FileResult{Path: file, Action: ActionRemoved})
+    }
+    results := []Result{central}
+
+    // Forget the removed files in every project, deleting unmodified copies if requested
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return results, fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    for _, projectPath := range meta.Paths {
+        base, err := loadBase(collectionName, projectPath)
+        if err != nil {
+            return results, err
+        }
+        mappings, err := loadMappings(collectionName, projectPath)
+        if err != nil {
+            return results, err
+        }
+        result := Result{Collection: collectionName, Project: projectPath, Action: "remove"}
+
+        for _, file := range removed {
+            state, tracked := base[file]
+            delete(base, file)
+            if !deleteCopies {
+                continue
+            }
+
+            projectFilePath := newFilePair(collectionName, projectPath, file, mappings).ProjectPath
+            checksum, err := fileChecksum(projectFilePath)
+            if err != nil {
+                return results, fmt.Errorf("failed to calculate checksum for project file %s: %w", projectFilePath, err)
+            }
+            if checksum == "" {
+                continue
+            }
+            if !tracked || checksum != state.Checksum {
+                result.Conflicts = append(result.Conflicts, projectFilePath)
+                continue
+            }
+            if err := removeFile(projectFilePath, projectPath); err != nil {
+                return results, fmt.Errorf("failed to delete %s from project: %w", projectFilePath, err)
+            }
+            result.Files = append(result.Files, FileResult{Path: file, Action: ActionDeleted})
+        }
+
+        if err := forgetFiles(collectionName, projectPath, removed); err != nil {
+            return results, err
+        }
+        results = append(results, result)
+    }
+
+    return results, nil
+}
+
+// forgetFiles drops files removed from a collection from the state recorded for the project at projectPath.
+// Only an existing manifest entry is changed, so removing files never adds a manifest to a project.
+func forgetFiles(collectionName, projectPath string, relPaths []string) error {
+    entry, err := findManifestEntry(collectionName, projectPath)
+    if err != nil {
+        return err
+    }
+    if entry == nil {
+        // Projects synced by older versions keep their base in the central metadata
+        meta, err := requireCollectionMeta(collectionName)
+        if err != nil {
+            return fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+        }
+        if meta.Bases[projectPath] == nil {
+            return nil
+        }
+        for _, relPath := range relPaths {
+            delete(meta.Bases[projectPath], relPath)
+        }
+        return writeCollectionMeta(collectionName, meta)
+    }
+
+    return updateManifestEntry(collectionName, projectPath, func(entry *ManifestEntry) {
+        unresolved := map[string]bool{}
+        for _, relPath := range entry.Unresolved {
+            unresolved[relPath] = true
+        }
+        for _, relPath := range relPaths {
+            delete(entry.Files, relPath)
+            delete(unresolved, relPath)
+        }
+        entry.Unresolved = sortedSet(unresolved)
+    })
+}
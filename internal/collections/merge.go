@@ -0,0 +1,353 @@
+// internal/collections/merge.go
+
+package collections
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// Conflict markers written around the two versions of a region that could not be merged.
+const (
+    markerProject = "<<<<<<< project\n"
+    markerDivider = "=======\n"
+    markerCentral = ">>>>>>> central\n"
+)
+
+// MergeText performs a line-based three-way merge of the project and central versions of a file
+// against their common ancestor. Regions changed on only one side are taken from that side;
+// regions changed differently on both sides are wrapped in conflict markers.
+// It returns the merged content and the number of conflicting regions.
+func MergeText(base, project, central []byte) ([]byte, int) {
+    baseLines, projectLines, centralLines := splitLines(base), splitLines(project), splitLines(central)
+    projectMatch := matchLines(baseLines, projectLines)
+    centralMatch := matchLines(baseLines, centralLines)
+
+    var merged strings.Builder
+    conflicts := 0
+    i, p, c := 0, 0, 0
+    for {
+        // Copy lines unchanged on both sides
+        for i < len(baseLines) && projectMatch[i] == p && centralMatch[i] == c {
+            merged.WriteString(baseLines[i])
+            i, p, c = i+1, p+1, c+1
+        }
+        if i == len(baseLines) && p == len(projectLines) && c == len(centralLines) {
+            break
+        }
+
+        // Find the next base line kept by both sides, which ends the changed region
+        end := i
+        for end < len(baseLines) && (projectMatch[end] < 0 || centralMatch[end] < 0) {
+            end++
+        }
+        projectEnd, centralEnd := len(projectLines), len(centralLines)
+        if end < len(baseLines) {
+            projectEnd, centralEnd = projectMatch[end], centralMatch[end]
+        }
+
+        baseChunk := baseLines[i:end]
+        projectChunk := projectLines[p:projectEnd]
+        centralChunk := centralLines[c:centralEnd]
+        switch {
+        case equalLines(projectChunk, baseChunk) || equalLines(projectChunk, centralChunk):
+            writeLines(&merged, centralChunk)
+        case equalLines(centralChunk, baseChunk):
+            writeLines(&merged, projectChunk)
+        default:
+            conflicts++
+            merged.WriteString(markerProject)
+            writeMarkedLines(&merged, projectChunk)
+            merged.WriteString(markerDivider)
+            writeMarkedLines(&merged, centralChunk)
+            merged.WriteString(markerCentral)
+        }
+        i, p, c = end, projectEnd, centralEnd
+    }
+    return []byte(merged.String()), conflicts
+}
+
+// HasConflictMarkers reports whether content still contains markers left by a conflicting merge.
+func HasConflictMarkers(data []byte) bool {
+    for _, line := range splitLines(data) {
+        if strings.HasPrefix(line, "<<<<<<<") || strings.HasPrefix(line, ">>>>>>>") {
+            return true
+        }
+    }
+    return false
+}
+
+// matchLines maps every base line to the index of the same line in the other version, or -1 if it was removed.
+func matchLines(base, other []string) []int {
+    match := make([]int, len(base))
+    i, j := 0, 0
+    for _, op := range diffLines(base, other) {
+        switch op.kind {
+        case ' ':
+            match[i] = j
+            i, j = i+1, j+1
+        case '-':
+            match[i] = -1
+            i++
+        case '+':
+            j++
+        }
+    }
+    return match
+}
+
+// equalLines reports whether two line slices hold the same lines.
+func equalLines(a, b []string) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
+
+func writeLines(b *strings.Builder, lines []string) {
+    for _, line := range lines {
+        b.WriteString(line)
+    }
+}
+
+// writeMarkedLines writes lines inside conflict markers, making sure the last one ends before the next marker.
+func writeMarkedLines(b *strings.Builder, lines []string) {
+    for _, line := range lines {
+        b.WriteString(line)
+        if !strings.HasSuffix(line, "\n") {
+            b.WriteString("\n")
+        }
+    }
+}
+
+// MergeFiles attempts a three-way merge of project files that changed both locally and in central,
+// using the base snapshot as the common ancestor. Clean merges are written to the project, and to
+// central when updateCentral is set. Conflicting text files get conflict markers in the project file;
+// binary files are left alone with the central and ancestor versions saved next to them as .theirs and
+// .orig files. Conflicts stay unresolved, and are skipped by later merges, until `pst resolve` is run.
+// It returns the files merged cleanly and the files left with conflicts.
+func MergeFiles(collectionName, projectPath string, files []string, updateCentral bool) ([]FileResult, []string, error) {
+    merged := []FileResult{}
+    unresolved := []string{}
+
+    pairs, err := lookupPairs(collectionName, projectPath)
+    if err != nil {
+        return merged, unresolved, err
+    }
+    base, err := loadBase(collectionName, projectPath)
+    if err != nil {
+        return merged, unresolved, err
+    }
+    entry, err := findManifestEntry(collectionName, projectPath)
+    if err != nil {
+        return merged, unresolved, err
+    }
+    pending := map[string]bool{}
+    if entry != nil {
+        for _, relPath := range entry.Unresolved {
+            pending[relPath] = true
+        }
+    }
+
+    // Central is kept as is for pinned projects
+    if pinned, err := pinnedRevision(collectionName, projectPath); err != nil {
+        return merged, unresolved, err
+    } else if pinned != nil {
+        updateCentral = false
+    }
+
+    // Every file is staged first and written in one transaction, so a failure leaves the project as it was
+    tx := &transaction{}
+    for _, file := range files {
+        pair, ok := pairs[filepath.Clean(file)]
+        if !ok {
+            return []FileResult{}, unresolved, tx.fail(fmt.Errorf("%s is not tracked by collection %s", file, collectionName))
+        }
+        if pending[pair.RelPath] {
+            unresolved = append(unresolved, file)
+            continue
+        }
+
+        // Deletions on either side can't be merged line by line
+        projectData, projectErr := os.ReadFile(file)
+        centralData, centralErr := os.ReadFile(pair.CentralPath)
+        if projectErr != nil || centralErr != nil {
+            pending[pair.RelPath] = true
+            unresolved = append(unresolved, file)
+            continue
+        }
+        projectData, centralData = pair.StripHeader(projectData), pair.Render(centralData)
+
+        // Without a recorded ancestor the whole file is treated as changed on both sides
+        var baseData []byte
+        state, hasBase := base[pair.RelPath]
+        if hasBase {
+            if baseData, err = readBlob(collectionName, state.Checksum); err != nil {
+                hasBase = false
+            }
+        }
+
+        if IsBinary(projectData) || IsBinary(centralData) || (hasBase && IsBinary(baseData)) {
+            if err := tx.stageContent(file+".theirs", centralData); err != nil {
+                return []FileResult{}, unresolved, tx.fail(fmt.Errorf("failed to write central version of %s: %w", file, err))
+            }
+            if hasBase {
+                if err := tx.stageContent(file+".orig", baseData); err != nil {
+                    return []FileResult{}, unresolved, tx.fail(fmt.Errorf("failed to write ancestor of %s: %w", file, err))
+                }
+            }
+            pending[pair.RelPath] = true
+            unresolved = append(unresolved, file)
+            continue
+        }
+
+        result, conflicts := MergeText(baseData, projectData, centralData)
+        result = pair.withHeader(result)
+        if err := tx.stageContent(file, result); err != nil {
+            return []FileResult{}, unresolved, tx.fail(fmt.Errorf("failed to write merged %s: %w", file, err))
+        }
+        if conflicts > 0 {
+            pending[pair.RelPath] = true
+            unresolved = append(unresolved, file)
+            continue
+        }
+
+        if updateCentral {
+            if convert := pair.converter(true); convert != nil {
+                if result, err = convert(result); err != nil {
+                    return []FileResult{}, unresolved, tx.fail(fmt.Errorf("failed to convert merged %s: %w", pair.RelPath, err))
+                }
+            }
+            if err := tx.stageContent(pair.CentralPath, result); err != nil {
+                return []FileResult{}, unresolved, tx.fail(fmt.Errorf("failed to copy merged %s to central collection: %w", pair.RelPath, err))
+            }
+        } else {
+            // The merged file already includes the central changes, so it only remains to be pushed
+            state, err := pair.centralState()
+            if err != nil {
+                return []FileResult{}, unresolved, tx.fail(err)
+            }
+            base[pair.RelPath] = state
+        }
+        merged = append(merged, FileResult{Path: pair.RelPath, Action: ActionMerged})
+    }
+
+    if err := tx.commit(); err != nil {
+        return []FileResult{}, unresolved, err
+    }
+
+    // Record the new base and the conflicts left to resolve, restoring the files if that fails
+    bookkeeping, err := bookkeepingFiles(collectionName, projectPath)
+    if err == nil {
+        err = tx.save(bookkeeping...)
+    }
+    if err == nil {
+        base, err = updateBase(base, collectionName, projectPath)
+    }
+    if err == nil {
+        err = updateManifestEntry(collectionName, projectPath, func(entry *ManifestEntry) {
+            entry.Files = base
+            entry.Unresolved = sortedSet(pending)
+        })
+    }
+    if err != nil {
+        return []FileResult{}, unresolved, tx.fail(err)
+    }
+    tx.cleanup()
+    return merged, unresolved, nil
+}
+
+// ResolveFiles marks merge conflicts in the given project files as resolved. The resolved files keep
+// their content and are treated as local changes on top of the current central files, ready to push.
+// Files that still contain conflict markers are refused unless force is set. Without files, every
+// unresolved file of the collection is resolved.
+func ResolveFiles(collectionName, projectPath string, files []string, force bool) ([]FileResult, error) {
+    resolved := []FileResult{}
+    entry, err := findManifestEntry(collectionName, projectPath)
+    if err != nil {
+        return resolved, err
+    }
+    if entry == nil || len(entry.Unresolved) == 0 {
+        return resolved, nil
+    }
+
+    pairs, err := lookupPairs(collectionName, projectPath)
+    if err != nil {
+        return resolved, err
+    }
+    byRelPath := map[string]FilePair{}
+    for _, pair := range pairs {
+        byRelPath[pair.RelPath] = pair
+    }
+    selected := map[string]bool{}
+    for _, file := range files {
+        selected[filepath.Clean(file)] = true
+    }
+
+    base, err := loadBase(collectionName, projectPath)
+    if err != nil {
+        return resolved, err
+    }
+    remaining := map[string]bool{}
+    for _, relPath := range entry.Unresolved {
+        pair, ok := byRelPath[relPath]
+        if !ok || (len(files) > 0 && !selected[filepath.Clean(pair.ProjectPath)]) {
+            remaining[relPath] = true
+            continue
+        }
+
+        data, err := os.ReadFile(pair.ProjectPath)
+        if err != nil && !os.IsNotExist(err) {
+            return resolved, fmt.Errorf("failed to read %s: %w", pair.ProjectPath, err)
+        }
+        if HasConflictMarkers(data) && !force {
+            return resolved, fmt.Errorf("%s still contains conflict markers. Use --force to resolve it anyway", pair.ProjectPath)
+        }
+
+        // Take the central version as the base, so the resolved content counts as a local change
+        state, err := pair.centralState()
+        if err != nil {
+            return resolved, err
+        }
+        if state.Checksum == "" {
+            delete(base, relPath)
+        } else {
+            base[relPath] = state
+        }
+        for _, suffix := range []string{".theirs", ".orig"} {
+            if err := journalFile(pair.ProjectPath + suffix); err != nil {
+                return resolved, err
+            }
+            if err := os.Remove(pair.ProjectPath + suffix); err != nil && !os.IsNotExist(err) {
+                return resolved, err
+            }
+        }
+        resolved = append(resolved, FileResult{Path: relPath, Action: ActionResolved})
+    }
+
+    return resolved, updateManifestEntry(collectionName, projectPath, func(entry *ManifestEntry) {
+        entry.Files = base
+        entry.Unresolved = sortedSet(remaining)
+    })
+}
+
+// sortedSet returns the members of a set in sorted order, or nil if it is empty.
+func sortedSet(set map[string]bool) []string {
+    if len(set) == 0 {
+        return nil
+    }
+    members := []string{}
+    for member := range set {
+        members = append(members, member)
+    }
+    sort.Strings(members)
+    return members
+}
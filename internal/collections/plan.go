@@ -0,0 +1,515 @@
+// internal/collections/plan.go
+
+package collections
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+)
+
+// Planned actions for a single file.
+const (
+    PlanCreate    = "create"    // The target doesn't exist yet
+    PlanOverwrite = "overwrite" // The target exists with different content
+    PlanDelete    = "delete"    // The target is removed
+    PlanSkip      = "skip"      // The target already has the same content
+    PlanConflict  = "conflict"  // The file changed on both sides and is left to merge or resolve
+)
+
+// PlannedFile is an operation on a single file in a plan.
+type PlannedFile struct {
+    Path   string // Path relative to the collection root
+    Action string // One of the Plan* actions
+    Source string // File copied to the target, empty for deletions
+    Target string // File written or deleted
+
+    result  string      // Action reported once the file is copied
+    root    string      // Directory that empty parents are removed up to after a deletion
+    mode    os.FileMode // Mode given to the target, taken from the source if zero
+    convert converter   // Conversion of the content on its way to the target, nil to copy it as is
+}
+
+// Plan lists every file an operation will create, overwrite, delete, skip or leave in conflict.
+// It is computed without touching disk, so it can be shown for a dry run or applied.
+type Plan struct {
+    Collection string
+    Project    string
+    Files      []PlannedFile
+
+    finish  func() error    // Bookkeeping run after the files are applied
+    filters []contentFilter // Filters that files added to the collection are converted back through
+    header  *provenance     // Provenance header stripped from files added to the collection
+}
+
+// addCopy plans copying source to target, comparing their content and mode to choose the action.
+// A non-zero mode overrides the mode of source, which is then a stored blob.
+func (p *Plan) addCopy(relPath, source, target, result string, mode os.FileMode) error {
+    return p.addConvertedCopy(relPath, source, target, result, mode, nil)
+}
+
+// addConvertedCopy plans copying source to target like addCopy, with the content passed through convert.
+// Symbolic links are copied as they are.
+func (p *Plan) addConvertedCopy(relPath, source, target, result string, mode os.FileMode, convert converter) error {
+    if mode&os.ModeSymlink != 0 {
+        convert = nil
+    }
+    sourceState, err := convertedState(source, convert)
+    if err != nil {
+        return fmt.Errorf("failed to calculate checksum for %s: %w", source, err)
+    }
+    if mode != 0 {
+        sourceState.Mode = mode
+    }
+    targetState, err := fileState(target)
+    if err != nil {
+        return fmt.Errorf("failed to calculate checksum for %s: %w", target, err)
+    }
+
+    action := PlanOverwrite
+    if targetState.Checksum == "" {
+        action = PlanCreate
+    } else if targetState == sourceState {
+        action = PlanSkip
+    } else if convert != nil && targetState.Mode == sourceState.Mode && targetState.Mode&os.ModeSymlink == 0 {
+        // Files that only differ in their provenance header keep the one they have
+        same, err := sameWithoutHeader(source, target, convert)
+        if err != nil {
+            return err
+        }
+        if same {
+            action = PlanSkip
+        }
+    }
+    p.Files = append(p.Files, PlannedFile{Path: relPath, Action: action, Source: source, Target: target, result: result, mode: mode, convert: convert})
+    return nil
+}
+
+// sameWithoutHeader reports whether source, converted, has the content of target once provenance headers are left out.
+func sameWithoutHeader(source, target string, convert converter) (bool, error) {
+    sourceData, err := os.ReadFile(source)
+    if err != nil {
+        return false, fmt.Errorf("failed to read %s: %w", source, err)
+    }
+    if sourceData, err = convert(sourceData); err != nil {
+        return false, err
+    }
+    targetData, err := os.ReadFile(target)
+    if err != nil {
+        return false, fmt.Errorf("failed to read %s: %w", target, err)
+    }
+    return bytes.Equal(stripHeader(sourceData), stripHeader(targetData)), nil
+}
+
+// addTree plans copying the file or directory tree at source into the collection as relPath.
+// Ignored files are skipped, and symbolic links are handled by the symlink policy, root being the
+// directory that matches the collection root. Planned paths are recorded in added.
+func (p *Plan) addTree(source, relPath, collectionPath, root, policy string, ignore ignoreRules, added map[string]bool) error {
+    return filepath.Walk(source, func(file string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        rel, err := filepath.Rel(source, file)
+        if err != nil {
+            return err
+        }
+        fileRelPath := filepath.Join(relPath, rel)
+        if fileRelPath != "." && ignore.Ignored(fileRelPath, file, info.IsDir()) {
+            if info.IsDir() {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        if info.IsDir() {
+            return nil
+        }
+
+        if info.Mode()&os.ModeSymlink != 0 {
+            linked, err := linkSource(file, root, policy)
+            if err != nil || linked == "" {
+                return err
+            }
+            if linked != file {
+                // Follow the link, which may point to a whole directory
+                return p.addTree(linked, fileRelPath, collectionPath, filepath.Dir(linked), SymlinksKeep, ignore, added)
+            }
+        }
+
+        added[fileRelPath] = true
+        pair := FilePair{RelPath: fileRelPath, CentralPath: filepath.Join(collectionPath, fileRelPath), ProjectPath: file, filters: p.filters, header: p.header}
+        return p.addConvertedCopy(fileRelPath, file, pair.CentralPath, ActionAdded, 0, pair.converter(true))
+    })
+}
+
+// addDelete plans deleting target, removing parent directories left empty up to root.
+func (p *Plan) addDelete(relPath, target, root string) {
+    p.Files = append(p.Files, PlannedFile{Path: relPath, Action: PlanDelete, Target: target, root: root})
+}
+
+// AddConflicts records project files that changed on both sides. They are left alone when the plan is applied.
+func (p *Plan) AddConflicts(files []string) error {
+    pairs, err := lookupPairs(p.Collection, p.Project)
+    if err != nil {
+        return err
+    }
+    for _, file := range files {
+        pair, ok := pairs[filepath.Clean(file)]
+        if !ok {
+            return fmt.Errorf("%s is not tracked by collection %s", file, p.Collection)
+        }
+        p.Files = append(p.Files, PlannedFile{Path: pair.RelPath, Action: PlanConflict, Source: pair.CentralPath, Target: file})
+    }
+    return nil
+}
+
+// Keep leaves the given targets out of the plan, such as project files deleted since the last sync that
+// a require would otherwise restore.
+func (p *Plan) Keep(files []string) {
+    kept := map[string]bool{}
+    for _, file := range files {
+        kept[filepath.Clean(file)] = true
+    }
+    planned := []PlannedFile{}
+    for _, file := range p.Files {
+        if !kept[filepath.Clean(file.Target)] {
+            planned = append(planned, file)
+        }
+    }
+    p.Files = planned
+}
+
+// SetPin writes the pin to the project manifest when the plan is applied, before the rest of the bookkeeping.
+func (p *Plan) SetPin(pin *Pin) {
+    if pin == nil {
+        return
+    }
+    finish := p.finish
+    p.finish = func() error {
+        if err := pin.write(); err != nil {
+            return err
+        }
+        if finish != nil {
+            return finish()
+        }
+        return nil
+    }
+}
+
+// Changes returns the planned action for every file in the plan.
+func (p *Plan) Changes() []FileResult {
+    changes := []FileResult{}
+    for _, file := range p.Files {
+        changes = append(changes, FileResult{Path: file.Path, Action: file.Action})
+    }
+    return changes
+}
+
+// PendingFiles returns the targets the plan creates, overwrites or deletes.
+func (p *Plan) PendingFiles() []string {
+    files := []string{}
+    for _, file := range p.Files {
+        switch file.Action {
+        case PlanCreate, PlanOverwrite, PlanDelete:
+            files = append(files, file.Target)
+        }
+    }
+    return files
+}
+
+// Apply performs the planned operations and returns the files changed. The operations are applied
+// as a transaction: every new file is staged first, and if any write or the bookkeeping afterwards
+// fails, the files already changed are restored along with the manifest and collection metadata.
+func (p *Plan) Apply() ([]FileResult, error) {
+    results := []FileResult{}
+    tx := &transaction{}
+    for _, file := range p.Files {
+        switch file.Action {
+        case PlanCreate, PlanOverwrite:
+            if err := tx.stageConverted(file.Source, file.Target, file.mode, file.convert); err != nil {
+                return []FileResult{}, tx.fail(fmt.Errorf("failed to copy %s: %w", file.Path, err))
+            }
+            results = append(results, FileResult{Path: file.Path, Action: file.result})
+        case PlanDelete:
+            tx.stageDelete(file.Target)
+            results = append(results, FileResult{Path: file.Path, Action: ActionDeleted})
+        }
+    }
+    if err := tx.commit(); err != nil {
+        return []FileResult{}, err
+    }
+
+    if p.finish != nil {
+        // The bookkeeping writes the manifest and the collection metadata, which are restored along with the files
+        files, err := bookkeepingFiles(p.Collection, p.Project)
+        if err == nil {
+            err = tx.save(files...)
+        }
+        if err == nil {
+            err = p.finish()
+        }
+        if err != nil {
+            return []FileResult{}, tx.fail(err)
+        }
+    }
+    tx.cleanup()
+
+    // Deleting files may leave empty directories behind
+    for _, file := range p.Files {
+        if file.Action == PlanDelete {
+            if err := removeEmptyParents(file.Target, file.root); err != nil {
+                return results, err
+            }
+        }
+    }
+    return results, nil
+}
+
+// bookkeepingFiles returns the files the bookkeeping of a change to a project may write: the metadata
+// of the collection and the manifest of the project.
+func bookkeepingFiles(collectionName, projectPath string) ([]string, error) {
+    if collectionName == "" {
+        return nil, nil
+    }
+    files := []string{getMetaFilePath(collectionName)}
+    if projectPath != "" {
+        projectPath, err := filepath.Abs(projectPath)
+        if err != nil {
+            return nil, fmt.Errorf("could not determine project path: %w", err)
+        }
+        root, err := projectRootFor(collectionName, projectPath)
+        if err != nil {
+            return nil, err
+        }
+        files = append(files, filepath.Join(root, ManifestFileName))
+    }
+    return files, nil
+}
+
+// addedPath returns the absolute path of a path added to a collection and its path relative to the project
+// root, which is where it goes in the collection. Paths outside the project are refused.
+func addedPath(path, projectRoot string) (string, string, error) {
+    absPath, err := filepath.Abs(path)
+    if err != nil {
+        return "", "", fmt.Errorf("could not determine path of %s: %w", path, err)
+    }
+    relPath, err := filepath.Rel(projectRoot, absPath)
+    if err != nil {
+        return "", "", fmt.Errorf("failed to calculate relative path for %s: %w", path, err)
+    }
+    if !isWithin(absPath, projectRoot) {
+        return "", "", fmt.Errorf("%s is outside the project %s", path, projectRoot)
+    }
+    return absPath, relPath, nil
+}
+
+// PlanAddToCollection plans copying paths relative to the current directory into the collection,
+// which registers the current directory as a project using it. With force, central files that
+// are not among the added paths are deleted, replacing the whole collection.
+func PlanAddToCollection(collectionName string, paths []string, force bool) (*Plan, error) {
+    collectionPath := GetCollectionPath(collectionName)
+
+    // Get the current working directory, which is the project root for the added paths
+    commandDir, err := os.Getwd()
+    if err != nil {
+        return nil, fmt.Errorf("could not determine working directory: %w", err)
+    }
+    ignore, err := loadIgnoreRules(collectionName, commandDir)
+    if err != nil {
+        return nil, err
+    }
+    policy, err := loadSymlinkPolicy(collectionName)
+    if err != nil {
+        return nil, err
+    }
+    filters, err := loadFilters(collectionName, commandDir)
+    if err != nil {
+        return nil, err
+    }
+    header, err := loadHeader(collectionName, nil)
+    if err != nil {
+        return nil, err
+    }
+
+    plan := &Plan{Collection: collectionName, Project: commandDir, filters: filters, header: header}
+    added := map[string]bool{}
+    for _, path := range paths {
+        absPath, relRoot, err := addedPath(path, commandDir)
+        if err != nil {
+            return nil, err
+        }
+
+        // Walk the path, preserving the relative directory structure and skipping ignored files
+        if err := plan.addTree(absPath, relRoot, collectionPath, commandDir, policy, ignore, added); err != nil {
+            return nil, fmt.Errorf("failed to add %s to collection: %w", path, err)
+        }
+    }
+
+    if force {
+        existing := []string{}
+        err := filepath.Walk(collectionPath, func(file string, info os.FileInfo, err error) error {
+            if os.IsNotExist(err) {
+                return filepath.SkipDir
+            } else if err != nil {
+                return err
+            }
+            relPath, err := filepath.Rel(collectionPath, file)
+            if err != nil {
+                return err
+            }
+            // Ignored central files, such as the repository of a git-backed collection, are kept
+            if relPath != "." && ignore.central.Match(relPath, info.IsDir()) {
+                if info.IsDir() {
+                    return filepath.SkipDir
+                }
+                return nil
+            }
+            if !info.IsDir() && !added[relPath] {
+                existing = append(existing, relPath)
+            }
+            return nil
+        })
+        if err != nil {
+            return nil, fmt.Errorf("failed to list files in collection %s: %w", collectionName, err)
+        }
+        sort.Strings(existing)
+        for _, relPath := range existing {
+            plan.addDelete(relPath, filepath.Join(collectionPath, relPath), collectionPath)
+        }
+    }
+
+    plan.finish = func() error {
+        if err := os.MkdirAll(collectionPath, os.ModePerm); err != nil {
+            return fmt.Errorf("failed to create collection directory: %w", err)
+        }
+        if err := saveCollectionMeta(collectionName, commandDir); err != nil {
+            return fmt.Errorf("failed to save collection metadata: %w", err)
+        }
+        return nil
+    }
+    return plan, nil
+}
+
+// PlanRequire plans copying every file in the collection to its mapped location in the target path,
+// which registers the target as a project using the collection.
+func PlanRequire(collectionName, targetPath string) (*Plan, error) {
+    // Check if the collection exists
+    if _, err := os.Stat(GetCollectionPath(collectionName)); os.IsNotExist(err) {
+        return nil, fmt.Errorf("collection %s does not exist", collectionName)
+    }
+
+    targetPath, err := filepath.Abs(targetPath)
+    if err != nil {
+        return nil, fmt.Errorf("could not determine target path: %w", err)
+    }
+    pairs, err := GetFilePairs(collectionName, targetPath)
+    if err != nil {
+        return nil, err
+    }
+
+    plan := &Plan{Collection: collectionName, Project: targetPath}
+    for _, pair := range pairs {
+        if err := plan.addConvertedCopy(pair.RelPath, pair.CentralPath, pair.ProjectPath, ActionCopied, pair.centralMode, pair.converter(false)); err != nil {
+            return nil, err
+        }
+    }
+    plan.finish = func() error {
+        if err := saveCollectionMeta(collectionName, targetPath); err != nil {
+            return fmt.Errorf("failed to save collection metadata: %w", err)
+        }
+        return nil
+    }
+    return plan, nil
+}
+
+// PlanPush plans copying the given project files into the central collection.
+// Files that no longer exist in the project are deleted from central.
+func PlanPush(collectionName, projectPath string, files []string) (*Plan, error) {
+    if pinned, err := pinnedRevision(collectionName, projectPath); err != nil {
+        return nil, err
+    } else if pinned != nil && len(files) > 0 {
+        return nil, fmt.Errorf("collection %s is pinned to a version in %s. Use require %s@latest to unpin it before pushing", collectionName, projectPath, collectionName)
+    }
+    pairs, err := lookupPairs(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+    policy, err := loadSymlinkPolicy(collectionName)
+    if err != nil {
+        return nil, err
+    }
+
+    plan := &Plan{Collection: collectionName, Project: projectPath}
+    for _, file := range files {
+        pair, ok := pairs[filepath.Clean(file)]
+        if !ok {
+            return nil, fmt.Errorf("%s is not tracked by collection %s", file, collectionName)
+        }
+        info, err := os.Lstat(file)
+        if os.IsNotExist(err) {
+            plan.addDelete(pair.RelPath, pair.CentralPath, GetCollectionPath(collectionName))
+            continue
+        }
+
+        source := file
+        if err == nil && info.Mode()&os.ModeSymlink != 0 {
+            if source, err = linkSource(file, projectPath, policy); err != nil {
+                return nil, err
+            } else if source == "" {
+                continue
+            }
+        }
+        if err := plan.addConvertedCopy(pair.RelPath, source, pair.CentralPath, ActionPushed, 0, pair.converter(true)); err != nil {
+            return nil, err
+        }
+    }
+    plan.finish = func() error {
+        return RecordBase(collectionName, projectPath)
+    }
+    return plan, nil
+}
+
+// PlanPull plans copying the central versions of the given project files into the project.
+// Files that no longer exist in central are deleted from the project.
+func PlanPull(collectionName, projectPath string, files []string) (*Plan, error) {
+    pairs, err := lookupPairs(collectionName, projectPath)
+    if err != nil {
+        return nil, err
+    }
+
+    plan := &Plan{Collection: collectionName, Project: projectPath}
+    if err := plan.addPulls(pairs, files); err != nil {
+        return nil, err
+    }
+    plan.finish = func() error {
+        return RecordBase(collectionName, projectPath)
+    }
+    return plan, nil
+}
+
+// AddPull plans pulling the given project files as well, as PlanPull does, so they are applied with the rest of the plan.
+func (p *Plan) AddPull(files []string) error {
+    pairs, err := lookupPairs(p.Collection, p.Project)
+    if err != nil {
+        return err
+    }
+    return p.addPulls(pairs, files)
+}
+
+// addPulls plans copying the central versions of project files into the project, or deleting them if they
+// no longer exist in central.
+func (p *Plan) addPulls(pairs map[string]FilePair, files []string) error {
+    for _, file := range files {
+        pair, ok := pairs[filepath.Clean(file)]
+        if !ok {
+            return fmt.Errorf("%s is not tracked by collection %s", file, p.Collection)
+        }
+        if _, err := os.Lstat(pair.CentralPath); os.IsNotExist(err) {
+            p.addDelete(pair.RelPath, file, p.Project)
+        } else if err := p.addConvertedCopy(pair.RelPath, pair.CentralPath, file, ActionPulled, pair.centralMode, pair.converter(false)); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,36 @@
+// internal/collections/result.go
+
+package collections
+
+// Actions reported for files touched by an operation.
+const (
+    ActionAdded    = "added"    // Copied from a project into central by init, or added in a revision
+    ActionModified = "modified" // Changed in a revision
+    ActionCopied   = "copied"   // Copied from central into a project by require
+    ActionPushed   = "pushed"   // Copied from a project into central by push or sync
+    ActionPulled   = "pulled"   // Copied from central into a project by sync
+    ActionDeleted  = "deleted"  // Removed to propagate a deletion from the other side, or deleted in a revision
+    ActionRemoved  = "removed"  // Removed from central by remove
+    ActionMerged   = "merged"   // Changed on both sides and merged without conflicts
+    ActionResolved = "resolved" // Marked as resolved after a conflicting merge
+    ActionRestored = "restored" // Restored to its content before an undone operation
+)
+
+// FileResult describes what an operation did to a single file.
+type FileResult struct {
+    Path   string `json:"path" yaml:"path"`
+    Action string `json:"action" yaml:"action"`
+}
+
+// Result describes the outcome of an operation on a collection.
+type Result struct {
+    Collection string         `json:"collection" yaml:"collection"`
+    Project    string         `json:"project,omitempty" yaml:"project,omitempty"`
+    Action     string         `json:"action" yaml:"action"`
+    Files      []FileResult   `json:"files" yaml:"files"`
+    Conflicts  []string       `json:"conflicts,omitempty" yaml:"conflicts,omitempty"`
+    Revision   *Revision      `json:"revision,omitempty" yaml:"revision,omitempty"`
+    Versions   *VersionStatus `json:"versions,omitempty" yaml:"versions,omitempty"`
+    Operation  *Operation     `json:"operation,omitempty" yaml:"operation,omitempty"`
+    DryRun     bool           `json:"dry_run,omitempty" yaml:"dry_run,omitempty"` // Files hold planned actions that were not applied
+}
@@ -0,0 +1,177 @@
+// internal/collections/rewrite.go
+
+package collections
+
+import (
+    "fmt"
+    "path"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// rewriteLanguages maps the extensions of source files to the language whose rewrite rules apply to them.
+var rewriteLanguages = map[string]string{
+    ".php": "php",
+    ".go":  "go",
+    ".js":  "js",
+    ".mjs": "js",
+    ".cjs": "js",
+    ".jsx": "js",
+    ".ts":  "js",
+    ".tsx": "js",
+}
+
+var (
+    phpStatementPattern = regexp.MustCompile(`^\s*(?:namespace|use)\s`)
+    phpNamePattern      = regexp.MustCompile(`\\?[A-Za-z_][A-Za-z0-9_]*(?:\\[A-Za-z_][A-Za-z0-9_]*)*\\?`)
+    goPackagePattern    = regexp.MustCompile(`^(\s*package\s+)([A-Za-z_][A-Za-z0-9_]*)`)
+    goImportPattern     = regexp.MustCompile(`^\s*import\s`)
+    goImportOpenPattern = regexp.MustCompile(`^\s*import\s*\(`)
+    goImportEndPattern  = regexp.MustCompile(`^\s*\)`)
+    goStringPattern     = regexp.MustCompile("\"[^\"]*\"|`[^`]*`")
+    jsStatementPattern  = regexp.MustCompile(`\b(?:import|export|from|require)\b`)
+    jsStringPattern     = regexp.MustCompile("'[^']*'|\"[^\"]*\"|`[^`]*`")
+)
+
+// rewriteFilter rewrites the namespace, package or import path of source files, which central files
+// declare with the collection's names and the project with its own.
+type rewriteFilter struct {
+    central map[string]string // Language -> name used by central files
+    project map[string]string // Language -> name used by the project
+}
+
+func (f rewriteFilter) toProject(relPath string, data []byte) []byte {
+    language := rewriteLanguages[strings.ToLower(filepath.Ext(relPath))]
+    return rewriteSource(language, data, f.central[language], f.project[language])
+}
+
+func (f rewriteFilter) toCentral(relPath string, data, central []byte) []byte {
+    language := rewriteLanguages[strings.ToLower(filepath.Ext(relPath))]
+    return rewriteSource(language, data, f.project[language], f.central[language])
+}
+
+// rewriteSource replaces the name from by to in the declarations and imports of a source file:
+// namespace and use statements in PHP, the package clause and imports in Go, and module
+// specifiers of import, export and require in JavaScript and TypeScript. Names nested in from,
+// such as the sub-namespaces of a PHP namespace or the packages below a Go import path, are
+// rewritten too. Other lines are left alone.
+func rewriteSource(language string, data []byte, from, to string) []byte {
+    if language == "" || from == "" || to == "" || from == to {
+        return data
+    }
+
+    lines := strings.SplitAfter(string(data), "\n")
+    inImports := false
+    for i, line := range lines {
+        switch language {
+        case "php":
+            if statement := phpStatementPattern.FindString(line); statement != "" {
+                lines[i] = statement + phpNamePattern.ReplaceAllStringFunc(line[len(statement):], func(name string) string {
+                    return replaceName(name, `\`, strings.Trim(from, `\`), strings.Trim(to, `\`))
+                })
+            }
+        case "go":
+            switch {
+            case inImports:
+                inImports = !goImportEndPattern.MatchString(line)
+                lines[i] = rewriteGoImport(line, from, to)
+            case goImportOpenPattern.MatchString(line):
+                inImports = !strings.Contains(line, ")")
+                lines[i] = rewriteGoImport(line, from, to)
+            case goImportPattern.MatchString(line):
+                lines[i] = rewriteGoImport(line, from, to)
+            default:
+                // The package clause names the last element of the import path, also in external tests
+                lines[i] = goPackagePattern.ReplaceAllStringFunc(line, func(clause string) string {
+                    match := goPackagePattern.FindStringSubmatch(clause)
+                    fromName, toName := path.Base(from), path.Base(to)
+                    if match[2] == fromName || match[2] == fromName+"_test" {
+                        return match[1] + toName + strings.TrimPrefix(match[2], fromName)
+                    }
+                    return clause
+                })
+            }
+        case "js":
+            if jsStatementPattern.MatchString(line) {
+                lines[i] = jsStringPattern.ReplaceAllStringFunc(line, func(literal string) string {
+                    quote := literal[:1]
+                    return quote + replaceName(literal[1:len(literal)-1], "/", from, to) + quote
+                })
+            }
+        }
+    }
+    return []byte(strings.Join(lines, ""))
+}
+
+// rewriteGoImport rewrites the import paths quoted in a line of a Go import declaration.
+func rewriteGoImport(line, from, to string) string {
+    return goStringPattern.ReplaceAllStringFunc(line, func(literal string) string {
+        quote := literal[:1]
+        return quote + replaceName(literal[1:len(literal)-1], "/", from, to) + quote
+    })
+}
+
+// replaceName returns name with the leading from replaced by to, if name is from or nested in it.
+// A leading separator, as in fully qualified PHP names, is kept.
+func replaceName(name, separator, from, to string) string {
+    prefix := ""
+    if separator == `\` && strings.HasPrefix(name, separator) {
+        prefix, name = separator, name[1:]
+    }
+    if name == from || strings.HasPrefix(name, from+separator) {
+        return prefix + to + name[len(from):]
+    }
+    return prefix + name
+}
+
+// checkRewrites returns an error unless every language of rewrites is supported and has a name.
+func checkRewrites(rewrites map[string]string) error {
+    for language, name := range rewrites {
+        switch language {
+        case "php", "go", "js":
+        default:
+            return fmt.Errorf("unsupported rewrite language %q: use php, go or js", language)
+        }
+        if strings.TrimSpace(name) == "" {
+            return fmt.Errorf("missing %s name to rewrite", language)
+        }
+    }
+    return nil
+}
+
+// SetCollectionRewrites records the PHP namespace, Go import path or JavaScript module path that
+// central files of a collection declare, so projects can rewrite them to their own.
+func SetCollectionRewrites(collectionName string, rewrites map[string]string) error {
+    if err := checkRewrites(rewrites); err != nil {
+        return err
+    }
+
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    if meta.Rewrites == nil {
+        meta.Rewrites = map[string]string{}
+    }
+    for language, name := range rewrites {
+        meta.Rewrites[language] = name
+    }
+    return writeCollectionMeta(collectionName, meta)
+}
+
+// SetRewrites records the names the project at projectPath uses instead of those of the collection.
+func SetRewrites(collectionName, projectPath string, rewrites map[string]string) error {
+    if err := checkRewrites(rewrites); err != nil {
+        return err
+    }
+
+    return updateManifestEntry(collectionName, projectPath, func(entry *ManifestEntry) {
+        if entry.Rewrites == nil {
+            entry.Rewrites = map[string]string{}
+        }
+        for language, name := range rewrites {
+            entry.Rewrites[language] = name
+        }
+    })
+}
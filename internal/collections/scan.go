@@ -7,39 +7,65 @@ import (
     "path/filepath"
     "gopkg.in/yaml.v3"
     "fmt"
+    "sort"
+
+    "github.com/forsvunnet/project-sync-tool/internal/config"
 )
 
-// scanForCollections searches the metadata directory for collections associated with the specified path.
+// ScanForCollections returns the collections declared in the manifest of the project containing dir,
+// followed by any other collections whose metadata lists the specified path.
 func ScanForCollections(dir string) ([]string, error) {
-    metaDir := filepath.Join(os.Getenv("HOME"), ".config", "project-sync-tool", "meta")
     collections := []string{}
+    found := map[string]bool{}
 
-    // Read the meta directory for each collection's YAML metadata file
-    entries, err := os.ReadDir(metaDir)
-    if err != nil {
-        return nil, fmt.Errorf("failed to read metadata directory: %w", err)
+    // Collections declared by the project manifest
+    if root, ok := FindProjectRoot(dir); ok {
+        manifest, err := LoadManifest(root)
+        if err != nil {
+            return nil, err
+        }
+        for _, name := range manifest.Names() {
+            found[name] = true
+            collections = append(collections, name)
+        }
     }
 
-    // Check each metadata file to see if the directory matches any listed project path
-    for _, entry := range entries {
-        if filepath.Ext(entry.Name()) != ".yml" {
+    // Check each metadata file of every store to see if the directory matches any listed project path
+    for _, store := range storeNames() {
+        metaDir := filepath.Join(config.StoreRoots()[store], "meta")
+        entries, err := os.ReadDir(metaDir)
+        if os.IsNotExist(err) {
             continue
+        } else if err != nil {
+            return nil, fmt.Errorf("failed to read metadata directory: %w", err)
         }
 
-        collectionName := entry.Name()[:len(entry.Name())-4] // Remove ".yml" extension
-        metaFilePath := filepath.Join(metaDir, entry.Name())
+        for _, entry := range entries {
+            if filepath.Ext(entry.Name()) != ".yml" {
+                continue
+            }
 
-        meta, err := loadCollectionMeta(metaFilePath)
-        if err != nil {
-            return nil, fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
-        }
+            collectionName := entry.Name()[:len(entry.Name())-4] // Remove ".yml" extension
+            if store != "" {
+                collectionName = store + "/" + collectionName
+            }
+            if found[collectionName] {
+                continue
+            }
+            metaFilePath := filepath.Join(metaDir, entry.Name())
 
-        for _, path := range meta.Paths {
-            absPath, _ := filepath.Abs(path)
-            targetAbsPath, _ := filepath.Abs(dir)
-            if absPath == targetAbsPath {
-                collections = append(collections, collectionName)
-                break
+            meta, err := loadCollectionMeta(metaFilePath)
+            if err != nil {
+                return nil, fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+            }
+
+            for _, path := range meta.Paths {
+                absPath, _ := filepath.Abs(path)
+                targetAbsPath, _ := filepath.Abs(dir)
+                if absPath == targetAbsPath {
+                    collections = append(collections, collectionName)
+                    break
+                }
             }
         }
     }
@@ -47,6 +73,16 @@ func ScanForCollections(dir string) ([]string, error) {
     return collections, nil
 }
 
+// storeNames returns the names of the named stores, sorted, after the default store's empty name.
+func storeNames() []string {
+    names := []string{}
+    for name := range config.StoreRoots() {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
 // loadCollectionMeta loads the metadata from a specified file path
 func loadCollectionMeta(metaFilePath string) (CollectionMeta, error) {
     meta := CollectionMeta{}
@@ -61,3 +97,43 @@ func loadCollectionMeta(metaFilePath string) (CollectionMeta, error) {
     return meta, nil
 }
 
+// ListProjectPaths returns every project path recorded in the collection metadata files that still exists.
+// Deleted projects are left out rather than recreated, as are the paths of other machines sharing a named store.
+func ListProjectPaths() ([]string, error) {
+    paths := []string{}
+    seen := map[string]bool{}
+
+    for _, store := range storeNames() {
+        metaDir := filepath.Join(config.StoreRoots()[store], "meta")
+        entries, err := os.ReadDir(metaDir)
+        if os.IsNotExist(err) {
+            continue
+        } else if err != nil {
+            return nil, fmt.Errorf("failed to read metadata directory: %w", err)
+        }
+
+        for _, entry := range entries {
+            if filepath.Ext(entry.Name()) != ".yml" {
+                continue
+            }
+
+            meta, err := loadCollectionMeta(filepath.Join(metaDir, entry.Name()))
+            if err != nil {
+                return nil, fmt.Errorf("failed to load metadata for %s: %w", entry.Name(), err)
+            }
+
+            for _, path := range meta.Paths {
+                if seen[path] {
+                    continue
+                }
+                if _, err := os.Stat(path); err != nil {
+                    continue
+                }
+                seen[path] = true
+                paths = append(paths, path)
+            }
+        }
+    }
+
+    return paths, nil
+}
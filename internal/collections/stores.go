@@ -0,0 +1,171 @@
+// internal/collections/stores.go
+
+package collections
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github.com/forsvunnet/project-sync-tool/internal/config"
+    "gopkg.in/yaml.v3"
+)
+
+// splitCollectionName splits a collection name qualified by a named store, as in team/common-utils.
+// The store is empty for collections in the default store.
+func splitCollectionName(collectionName string) (string, string) {
+    if store, name, found := strings.Cut(collectionName, "/"); found {
+        return store, name
+    }
+    return "", collectionName
+}
+
+// getCollectionStorePath returns a path inside the store holding a collection. Collections of an unknown
+// store, which IsValidCollectionName rejects, resolve to a directory of the default store that is never written.
+func getCollectionStorePath(collectionName string, elem ...string) string {
+    store, _ := splitCollectionName(collectionName)
+    if store == "" {
+        return getStorePath(elem...)
+    }
+    root, err := config.NamedStore(store)
+    if err != nil {
+        root = getStorePath("unknown-stores", store)
+    }
+    return filepath.Join(append([]string{root}, elem...)...)
+}
+
+// isObjectPath reports whether path is a blob in the object store of any known store.
+func isObjectPath(path string) bool {
+    for _, root := range config.StoreRoots() {
+        if isWithin(path, filepath.Join(root, "objects")) {
+            return true
+        }
+    }
+    return false
+}
+
+// storeRootOf returns the root of the store containing path, if any.
+func storeRootOf(path string) (string, bool) {
+    for _, root := range config.StoreRoots() {
+        if isWithin(path, root) {
+            return root, true
+        }
+    }
+    return "", false
+}
+
+// TransferCollection copies a collection with its history, tags and settings from one store to another,
+// as done by publish and fetch, and returns the central files changed in the target. Unless force is set,
+// the latest revision of the target must be part of the source history, so no revisions are lost.
+func TransferCollection(source, target string, force bool) ([]FileResult, error) {
+    head, err := LoadHead(source)
+    if err != nil {
+        return nil, err
+    } else if head == nil {
+        return nil, fmt.Errorf("collection %s has no history to transfer", source)
+    }
+    history, err := GetHistory(source)
+    if err != nil {
+        return nil, err
+    }
+
+    // Refuse to drop revisions or move tags only the target knows about
+    targetHead, err := LoadHead(target)
+    if err != nil {
+        return nil, err
+    }
+    if targetHead != nil && !force && !inHistory(history, targetHead.ID) {
+        return nil, fmt.Errorf("%s has revisions that %s doesn't have. Transfer them the other way first or use --force", target, source)
+    }
+    sourceTags, err := LoadTags(source)
+    if err != nil {
+        return nil, err
+    }
+    tags, err := LoadTags(target)
+    if err != nil {
+        return nil, err
+    }
+    for tag, id := range sourceTags {
+        if existing, ok := tags[tag]; ok && existing != id && !force {
+            return nil, fmt.Errorf("tag %s points to different revisions in %s and %s. Use --force to move it", tag, source, target)
+        }
+        tags[tag] = id
+    }
+
+    // Copy the revisions missing from the target, with the blobs they refer to
+    if err := os.MkdirAll(getHistoryPath(target), os.ModePerm); err != nil {
+        return nil, fmt.Errorf("failed to create history directory: %w", err)
+    }
+    for _, revision := range history {
+        revisionPath := filepath.Join(getHistoryPath(target), revision.ID+".yml")
+        if _, err := os.Stat(revisionPath); err == nil {
+            continue
+        }
+        for _, checksum := range revision.Files {
+            if err := writeBlob(target, getBlobPath(source, checksum), checksum); err != nil {
+                return nil, err
+            }
+        }
+        data, err := os.ReadFile(filepath.Join(getHistoryPath(source), revision.ID+".yml"))
+        if err != nil {
+            return nil, fmt.Errorf("failed to read revision %s of %s: %w", revision.ID, source, err)
+        }
+        if err := writeFileAtomic(revisionPath, bytes.NewReader(data)); err != nil {
+            return nil, fmt.Errorf("failed to copy revision %s: %w", revision.ID, err)
+        }
+    }
+
+    // Replace the target files with those of the latest revision
+    if err := os.MkdirAll(GetCollectionPath(target), os.ModePerm); err != nil {
+        return nil, fmt.Errorf("failed to create collection directory: %w", err)
+    }
+    plan, err := planRevisionFiles(target, head)
+    if err != nil {
+        return nil, err
+    }
+    files, err := plan.Apply()
+    if err != nil {
+        return files, err
+    }
+
+    // The target keeps its own project registrations, but takes the settings of the source
+    meta, err := requireCollectionMeta(source)
+    if err != nil {
+        return files, fmt.Errorf("failed to load metadata for %s: %w", source, err)
+    }
+    targetMeta, err := requireCollectionMeta(target)
+    if err != nil {
+        return files, fmt.Errorf("failed to load metadata for %s: %w", target, err)
+    }
+    // Hooks run commands, so they are not carried to another store
+    targetMeta.Ignore, targetMeta.Symlinks, targetMeta.Rewrites, targetMeta.Header = meta.Ignore, meta.Symlinks, meta.Rewrites, meta.Header
+    if err := writeCollectionMeta(target, targetMeta); err != nil {
+        return files, err
+    }
+
+    if len(tags) > 0 {
+        data, err := yaml.Marshal(tags)
+        if err != nil {
+            return files, fmt.Errorf("failed to marshal tags: %w", err)
+        }
+        if err := writeFileAtomic(getTagsPath(target), bytes.NewReader(data)); err != nil {
+            return files, fmt.Errorf("failed to write tags of %s: %w", target, err)
+        }
+    }
+    if err := writeFileAtomic(filepath.Join(getHistoryPath(target), "HEAD"), strings.NewReader(head.ID+"\n")); err != nil {
+        return files, fmt.Errorf("failed to update history of %s: %w", target, err)
+    }
+    return files, nil
+}
+
+// inHistory reports whether a revision ID is part of a history.
+func inHistory(history []Revision, id string) bool {
+    for _, revision := range history {
+        if revision.ID == id {
+            return true
+        }
+    }
+    return false
+}
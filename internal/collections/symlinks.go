@@ -0,0 +1,82 @@
+// internal/collections/symlinks.go
+
+package collections
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+
+    "github.com/forsvunnet/project-sync-tool/internal/config"
+)
+
+// Policies for symbolic links pointing outside the collection. Links pointing inside it are always kept as links.
+const (
+    SymlinksFollow = "follow" // Copy the file or directory the link points to
+    SymlinksKeep   = "keep"   // Copy the link as is, even though it may not resolve in other projects
+    SymlinksSkip   = "skip"   // Leave the link out of the collection
+    SymlinksError  = "error"  // Refuse to copy the link
+)
+
+// SetSymlinkPolicy stores how a collection handles symbolic links pointing outside it.
+func SetSymlinkPolicy(collectionName, policy string) error {
+    switch policy {
+    case SymlinksFollow, SymlinksKeep, SymlinksSkip, SymlinksError:
+    default:
+        return fmt.Errorf("unsupported symlink policy %q: use follow, keep, skip or error", policy)
+    }
+
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    meta.Symlinks = policy
+    return writeCollectionMeta(collectionName, meta)
+}
+
+// loadSymlinkPolicy returns the symlink policy of a collection, falling back to the configured
+// default and then to following links.
+func loadSymlinkPolicy(collectionName string) (string, error) {
+    meta, err := requireCollectionMeta(collectionName)
+    if err != nil {
+        return "", fmt.Errorf("failed to load metadata for %s: %w", collectionName, err)
+    }
+    if meta.Symlinks != "" {
+        return meta.Symlinks, nil
+    }
+    settings, err := config.Load()
+    if err != nil {
+        return "", err
+    }
+    if settings.Symlinks != "" {
+        return settings.Symlinks, nil
+    }
+    return SymlinksFollow, nil
+}
+
+// linkSource returns the path to copy into the collection for the symbolic link at file, where root is the
+// directory matching the collection root. Relative links pointing inside root are copied as links, while
+// other links are handled by the policy. An empty path means the link is skipped.
+func linkSource(file, root, policy string) (string, error) {
+    link, err := os.Readlink(file)
+    if err != nil {
+        return "", fmt.Errorf("failed to read link %s: %w", file, err)
+    }
+    if !filepath.IsAbs(link) && isWithin(filepath.Join(filepath.Dir(file), link), root) {
+        return file, nil
+    }
+
+    switch policy {
+    case SymlinksKeep:
+        return file, nil
+    case SymlinksSkip:
+        return "", nil
+    case SymlinksError:
+        return "", fmt.Errorf("%s links to %s outside the collection", file, link)
+    }
+    resolved, err := filepath.EvalSymlinks(file)
+    if err != nil {
+        return "", fmt.Errorf("failed to follow link %s: %w", file, err)
+    }
+    return resolved, nil
+}
@@ -0,0 +1,138 @@
+// internal/collections/sync.go
+
+package collections
+
+import (
+    "fmt"
+    "path/filepath"
+)
+
+// SyncTarget pairs a collection with a project directory and the changes found between them.
+type SyncTarget struct {
+    Collection string
+    Project    string
+    Status     ChangeStatus
+    Version    string // Version tag the project is pinned to, if any
+}
+
+// FindSyncConflicts returns the files that changed on both sides of a sync: files changed both in a
+// project and in central, and central files that more than one project wants to update with different
+// content. Projects only push with update, local deletions only count with prune, and pinned projects
+// never push.
+func FindSyncConflicts(targets []SyncTarget, update, prune bool) ([]string, error) {
+    conflicts := []string{}
+    for _, target := range targets {
+        for _, file := range target.Status.Conflicts {
+            conflicts = append(conflicts, fmt.Sprintf("%s (%s)", file, target.Collection))
+        }
+    }
+
+    // Track the checksum each project would push to a central file
+    pending := map[string]string{}
+    reported := map[string]bool{}
+    for _, target := range targets {
+        if !update || target.Version != "" {
+            continue
+        }
+        pairs, err := lookupPairs(target.Collection, target.Project)
+        if err != nil {
+            return nil, err
+        }
+
+        for _, file := range localChanges(target.Status, prune) {
+            pair, ok := pairs[filepath.Clean(file)]
+            if !ok {
+                return nil, fmt.Errorf("%s is not tracked by collection %s", file, target.Collection)
+            }
+            relPath, centralPath := pair.RelPath, pair.CentralPath
+
+            // Deleted files have an empty checksum
+            state, err := pair.projectState()
+            if err != nil {
+                return nil, fmt.Errorf("failed to calculate checksum for project file %s: %w", file, err)
+            }
+            checksum := state.Checksum
+
+            existing, ok := pending[centralPath]
+            if !ok {
+                pending[centralPath] = checksum
+                continue
+            }
+            if existing != checksum && !reported[centralPath] {
+                reported[centralPath] = true
+                conflicts = append(conflicts, fmt.Sprintf("%s (%s)", relPath, target.Collection))
+            }
+        }
+    }
+    return conflicts, nil
+}
+
+// localChanges returns the files of a status that a sync pushes, including deletions with prune.
+func localChanges(status ChangeStatus, prune bool) []string {
+    files := append([]string{}, status.LocalNewer...)
+    if prune {
+        files = append(files, status.LocalDeleted...)
+    }
+    return files
+}
+
+// PlanSync plans a sync of the given targets without touching disk. With update, local changes are
+// planned as pushes, and files they update in central are planned as pulls into the other projects.
+// Files changed on both sides are listed as conflicts.
+func PlanSync(targets []SyncTarget, update, prune bool) ([]*Plan, error) {
+    plans := []*Plan{}
+    pushed := map[string]PlannedFile{} // Central path -> push that updates it
+    for _, target := range targets {
+        plan := &Plan{Collection: target.Collection, Project: target.Project}
+        if update && target.Version == "" {
+            push, err := PlanPush(target.Collection, target.Project, localChanges(target.Status, prune))
+            if err != nil {
+                return nil, err
+            }
+            for _, file := range push.Files {
+                pushed[file.Target] = file
+            }
+            plan.Files = append(plan.Files, push.Files...)
+        }
+        plans = append(plans, plan)
+    }
+
+    for i, target := range targets {
+        files := append(append([]string{}, target.Status.CentralNewer...), target.Status.MissingLocal...)
+        if prune {
+            files = append(files, target.Status.CentralDeleted...)
+        }
+        pull, err := PlanPull(target.Collection, target.Project, files)
+        if err != nil {
+            return nil, err
+        }
+        plans[i].Files = append(plans[i].Files, pull.Files...)
+
+        // Files in sync with central receive what other projects push in the same run
+        if len(pushed) > 0 {
+            pairs, err := lookupPairs(target.Collection, target.Project)
+            if err != nil {
+                return nil, err
+            }
+            for _, file := range target.Status.Unchanged {
+                pair := pairs[filepath.Clean(file)]
+                push, ok := pushed[pair.CentralPath]
+                if !ok {
+                    continue
+                }
+                if push.Action == PlanDelete {
+                    if prune {
+                        plans[i].addDelete(pair.RelPath, file, target.Project)
+                    }
+                } else if err := plans[i].addConvertedCopy(pair.RelPath, push.Source, file, ActionPulled, 0, chainConverters(push.convert, pair.converter(false))); err != nil {
+                    return nil, err
+                }
+            }
+        }
+
+        if err := plans[i].AddConflicts(target.Status.Conflicts); err != nil {
+            return nil, err
+        }
+    }
+    return plans, nil
+}
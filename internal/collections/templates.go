@@ -0,0 +1,112 @@
+// internal/collections/templates.go
+
+package collections
+
+import (
+    "fmt"
+    "regexp"
+    "sort"
+)
+
+// placeholderPattern matches placeholders such as {{ .Namespace }} in central files.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// variableNamePattern matches valid variable names.
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// templateFilter fills in the placeholders of central files with the values a project declares for them.
+type templateFilter struct {
+    variables map[string]string
+}
+
+// toProject replaces every placeholder with its value. Placeholders without a value are left as they are.
+func (f templateFilter) toProject(relPath string, data []byte) []byte {
+    return placeholderPattern.ReplaceAllFunc(data, func(placeholder []byte) []byte {
+        name := placeholderPattern.FindSubmatch(placeholder)[1]
+        if value, ok := f.variables[string(name)]; ok {
+            return []byte(value)
+        }
+        return placeholder
+    })
+}
+
+// toCentral replaces the values of variables with their placeholders, spelled as in the central file.
+// Only variables the central file has placeholders for are mapped back, so values that merely occur
+// in its text are kept; files new to central take every variable.
+func (f templateFilter) toCentral(relPath string, data, central []byte) []byte {
+    placeholders := map[string]string{}
+    if central != nil {
+        for _, match := range placeholderPattern.FindAllSubmatch(central, -1) {
+            if _, ok := placeholders[string(match[1])]; !ok {
+                placeholders[string(match[1])] = string(match[0])
+            }
+        }
+    } else {
+        for name := range f.variables {
+            placeholders[name] = "{{ ." + name + " }}"
+        }
+    }
+
+    // Longer values go first, so a value containing another is mapped back as a whole
+    values := []string{}
+    names := map[string]string{}
+    for name, placeholder := range placeholders {
+        value := f.variables[name]
+        if value == "" {
+            continue
+        }
+        // Variables sharing a value map back to the same placeholder whatever the map order
+        if existing, ok := names[value]; !ok {
+            names[value] = placeholder
+            values = append(values, value)
+        } else if placeholder < existing {
+            names[value] = placeholder
+        }
+    }
+    if len(values) == 0 {
+        return data
+    }
+    sort.Slice(values, func(i, j int) bool {
+        if len(values[i]) != len(values[j]) {
+            return len(values[i]) > len(values[j])
+        }
+        return values[i] < values[j]
+    })
+    quoted := ""
+    for i, value := range values {
+        if i > 0 {
+            quoted += "|"
+        }
+        quoted += regexp.QuoteMeta(value)
+    }
+
+    return regexp.MustCompile(quoted).ReplaceAllFunc(data, func(value []byte) []byte {
+        return []byte(names[string(value)])
+    })
+}
+
+// SetVariables sets the values the placeholders of central files take in the project at projectPath.
+// An empty value removes a variable.
+func SetVariables(collectionName, projectPath string, variables map[string]string) error {
+    for name := range variables {
+        if !variableNamePattern.MatchString(name) {
+            return fmt.Errorf("invalid variable name %q: use letters, digits and underscores", name)
+        }
+    }
+
+    return updateManifestEntry(collectionName, projectPath, func(entry *ManifestEntry) {
+        if entry.Variables == nil {
+            entry.Variables = map[string]string{}
+        }
+        for name, value := range variables {
+            if value == "" {
+                delete(entry.Variables, name)
+            } else {
+                entry.Variables[name] = value
+            }
+        }
+        if len(entry.Variables) == 0 {
+            entry.Variables = nil
+        }
+    })
+}
@@ -0,0 +1,348 @@
+// internal/collections/transaction.go
+
+package collections
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+)
+
+// PreserveTimes makes copies keep the modification time of the file they were copied from.
+var PreserveTimes bool
+
+// transaction applies a set of file writes and deletions so that either all of them take effect or none do.
+// New content is staged in temporary files next to their targets and synced to disk first; the targets are
+// then replaced by renames, with their previous content moved aside so it can be restored on failure.
+type transaction struct {
+    steps       []transactionStep
+    createdDirs []string
+    saved       []savedFile
+}
+
+// transactionStep is a single file replaced or deleted by a transaction.
+type transactionStep struct {
+    target string
+    staged string // Temporary file holding the new content, empty for deletions
+    backup string // Previous content moved aside, empty if the target didn't exist
+    done   bool
+}
+
+// savedFile is the content of a file written outside the staged steps, such as a manifest updated once the
+// files are in place, which a rollback puts back.
+type savedFile struct {
+    path    string
+    data    []byte
+    existed bool
+}
+
+// stageCopy writes a copy of source to a temporary file next to target. The copy gets the given mode,
+// or the mode of source if it is zero. Symbolic links are copied as links; with an explicit mode the
+// source holds the path a link points to, as stored in a blob.
+func (t *transaction) stageCopy(source, target string, mode os.FileMode) error {
+    info, err := os.Lstat(source)
+    if err != nil {
+        return fmt.Errorf("failed to open source file %s: %w", source, err)
+    }
+    explicit := mode != 0
+    if !explicit {
+        mode = fileMode(info)
+    }
+
+    var staged string
+    if mode&os.ModeSymlink != 0 {
+        var link []byte
+        if info.Mode()&os.ModeSymlink != 0 {
+            var target string
+            target, err = os.Readlink(source)
+            link = []byte(target)
+        } else {
+            link, err = os.ReadFile(source)
+        }
+        if err != nil {
+            return fmt.Errorf("failed to read link %s: %w", source, err)
+        }
+        if staged, err = t.stageSymlink(target, string(link)); err != nil {
+            return err
+        }
+    } else {
+        in, err := os.Open(source)
+        if err != nil {
+            return fmt.Errorf("failed to open source file %s: %w", source, err)
+        }
+        defer in.Close()
+
+        if staged, err = t.stage(target, in, mode.Perm()); err != nil {
+            return err
+        }
+        if PreserveTimes && !explicit {
+            if err := os.Chtimes(staged, info.ModTime(), info.ModTime()); err != nil {
+                os.Remove(staged)
+                return fmt.Errorf("failed to set modification time of %s: %w", target, err)
+            }
+        }
+    }
+    t.steps = append(t.steps, transactionStep{target: target, staged: staged})
+    return nil
+}
+
+// stageConverted stages a copy of source like stageCopy, with the content passed through convert first.
+// Symbolic links, and blobs holding them, are copied as they are.
+func (t *transaction) stageConverted(source, target string, mode os.FileMode, convert converter) error {
+    info, err := os.Lstat(source)
+    if err != nil {
+        return fmt.Errorf("failed to open source file %s: %w", source, err)
+    }
+    if convert == nil || mode&os.ModeSymlink != 0 || info.Mode()&os.ModeSymlink != 0 {
+        return t.stageCopy(source, target, mode)
+    }
+    explicit := mode != 0
+    if !explicit {
+        mode = fileMode(info)
+    }
+
+    data, err := os.ReadFile(source)
+    if err != nil {
+        return fmt.Errorf("failed to read source file %s: %w", source, err)
+    }
+    if data, err = convert(data); err != nil {
+        return err
+    }
+    staged, err := t.stage(target, bytes.NewReader(data), mode.Perm())
+    if err != nil {
+        return err
+    }
+    if PreserveTimes && !explicit {
+        if err := os.Chtimes(staged, info.ModTime(), info.ModTime()); err != nil {
+            os.Remove(staged)
+            return fmt.Errorf("failed to set modification time of %s: %w", target, err)
+        }
+    }
+    t.steps = append(t.steps, transactionStep{target: target, staged: staged})
+    return nil
+}
+
+// stageSymlink creates a symbolic link pointing to link next to target.
+func (t *transaction) stageSymlink(target, link string) (string, error) {
+    if err := t.mkdirAll(filepath.Dir(target)); err != nil {
+        return "", fmt.Errorf("failed to create directory for %s: %w", target, err)
+    }
+    staged, err := t.reserve(target, "link")
+    if err == nil {
+        err = os.Symlink(link, staged)
+    }
+    if err != nil {
+        return "", fmt.Errorf("failed to create link %s: %w", target, err)
+    }
+    return staged, nil
+}
+
+// stageContent writes content to a temporary file next to target, keeping the permissions of target if it exists.
+func (t *transaction) stageContent(target string, content []byte) error {
+    staged, err := t.stage(target, bytes.NewReader(content), 0)
+    if err != nil {
+        return err
+    }
+    t.steps = append(t.steps, transactionStep{target: target, staged: staged})
+    return nil
+}
+
+// save remembers the current content of files that are about to be written directly, so a rollback restores them.
+func (t *transaction) save(paths ...string) error {
+    for _, path := range paths {
+        data, err := os.ReadFile(path)
+        if os.IsNotExist(err) {
+            t.saved = append(t.saved, savedFile{path: path})
+            continue
+        } else if err != nil {
+            return fmt.Errorf("failed to read %s: %w", path, err)
+        }
+        t.saved = append(t.saved, savedFile{path: path, data: data, existed: true})
+    }
+    return nil
+}
+
+// stageDelete schedules the deletion of target.
+func (t *transaction) stageDelete(target string) {
+    t.steps = append(t.steps, transactionStep{target: target})
+}
+
+// stage copies content into a synced temporary file in the directory of target, creating the directory if needed.
+// The file gets the given permissions, or those of the file it replaces if zero.
+func (t *transaction) stage(target string, content io.Reader, perm os.FileMode) (string, error) {
+    if err := t.mkdirAll(filepath.Dir(target)); err != nil {
+        return "", fmt.Errorf("failed to create directory for %s: %w", target, err)
+    }
+
+    temp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".pst-")
+    if err != nil {
+        return "", fmt.Errorf("failed to create temporary file for %s: %w", target, err)
+    }
+    if _, err = io.Copy(temp, content); err == nil {
+        err = temp.Sync()
+    }
+    if closeErr := temp.Close(); err == nil {
+        err = closeErr
+    }
+    if err == nil {
+        if perm == 0 {
+            perm = 0644
+            if info, statErr := os.Stat(target); statErr == nil && info.Mode().IsRegular() {
+                perm = info.Mode().Perm()
+            }
+        }
+        err = os.Chmod(temp.Name(), perm)
+    }
+    if err != nil {
+        os.Remove(temp.Name())
+        return "", fmt.Errorf("failed to write temporary file for %s: %w", target, err)
+    }
+    return temp.Name(), nil
+}
+
+// mkdirAll creates a directory and its parents, remembering the ones created so a rollback can remove them.
+func (t *transaction) mkdirAll(dir string) error {
+    missing := []string{}
+    for current := dir; ; current = filepath.Dir(current) {
+        if _, err := os.Stat(current); err == nil || filepath.Dir(current) == current {
+            break
+        }
+        missing = append(missing, current)
+    }
+    if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+        return err
+    }
+    for i := len(missing) - 1; i >= 0; i-- {
+        t.createdDirs = append(t.createdDirs, missing[i])
+    }
+    return nil
+}
+
+// commit moves the staged files into place and performs the deletions, rolling everything back on failure.
+func (t *transaction) commit() error {
+    for i := range t.steps {
+        step := &t.steps[i]
+        if err := journalFile(step.target); err != nil {
+            return t.fail(err)
+        }
+
+        // Move the current content aside so it can be restored
+        if info, err := os.Lstat(step.target); err == nil {
+            if info.IsDir() {
+                return t.fail(fmt.Errorf("failed to replace %s: it is a directory", step.target))
+            }
+            backup, err := t.reserve(step.target, "backup")
+            if err == nil {
+                err = os.Rename(step.target, backup)
+            }
+            if err != nil {
+                return t.fail(fmt.Errorf("failed to replace %s: %w", step.target, err))
+            }
+            step.backup = backup
+        }
+        step.done = true
+
+        if step.staged != "" {
+            if err := os.Rename(step.staged, step.target); err != nil {
+                return t.fail(fmt.Errorf("failed to replace %s: %w", step.target, err))
+            }
+            step.staged = ""
+        }
+    }
+    return nil
+}
+
+// reserve returns an unused path next to target.
+func (t *transaction) reserve(target, purpose string) (string, error) {
+    temp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".pst-"+purpose+"-")
+    if err != nil {
+        return "", err
+    }
+    temp.Close()
+    return temp.Name(), os.Remove(temp.Name())
+}
+
+// fail rolls the transaction back and returns err, including any problem met while rolling back.
+func (t *transaction) fail(err error) error {
+    if rollbackErr := t.rollback(); rollbackErr != nil {
+        return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+    }
+    return err
+}
+
+// rollback restores every target to its content before the transaction and removes the staged files.
+func (t *transaction) rollback() error {
+    errs := []error{}
+    for i := len(t.saved) - 1; i >= 0; i-- {
+        saved := t.saved[i]
+        if !saved.existed {
+            if err := os.Remove(saved.path); err != nil && !os.IsNotExist(err) {
+                errs = append(errs, fmt.Errorf("failed to remove %s: %w", saved.path, err))
+            }
+            continue
+        }
+        staged, err := (&transaction{}).stage(saved.path, bytes.NewReader(saved.data), 0)
+        if err == nil {
+            if err = os.Rename(staged, saved.path); err != nil {
+                os.Remove(staged)
+            }
+        }
+        if err != nil {
+            errs = append(errs, fmt.Errorf("failed to restore %s: %w", saved.path, err))
+        }
+    }
+    t.saved = nil
+    for i := len(t.steps) - 1; i >= 0; i-- {
+        step := t.steps[i]
+        if step.staged != "" {
+            os.Remove(step.staged)
+        }
+        if !step.done {
+            continue
+        }
+        if step.backup != "" {
+            if err := os.Rename(step.backup, step.target); err != nil {
+                errs = append(errs, fmt.Errorf("failed to restore %s: %w", step.target, err))
+            }
+        } else if err := os.Remove(step.target); err != nil && !os.IsNotExist(err) {
+            errs = append(errs, fmt.Errorf("failed to remove %s: %w", step.target, err))
+        }
+    }
+    for i := len(t.createdDirs) - 1; i >= 0; i-- {
+        os.Remove(t.createdDirs[i])
+    }
+    t.steps = nil
+    return errors.Join(errs...)
+}
+
+// cleanup removes the previous content kept for a rollback once the transaction has succeeded.
+func (t *transaction) cleanup() {
+    for _, step := range t.steps {
+        if step.backup != "" {
+            os.Remove(step.backup)
+        }
+    }
+    t.steps = nil
+    t.saved = nil
+}
+
+// writeFileAtomic replaces path with the content read from r, so readers never see a partially written file.
+// The file keeps its permissions if it exists.
+func writeFileAtomic(path string, r io.Reader) error {
+    if err := journalFile(path); err != nil {
+        return err
+    }
+    t := &transaction{}
+    staged, err := t.stage(path, r, 0)
+    if err != nil {
+        return err
+    }
+    if err := os.Rename(staged, path); err != nil {
+        os.Remove(staged)
+        return fmt.Errorf("failed to replace %s: %w", path, err)
+    }
+    return nil
+}
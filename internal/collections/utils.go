@@ -4,16 +4,25 @@ package collections
 import (
     "errors"
     "regexp"
+
+    "github.com/forsvunnet/project-sync-tool/internal/config"
 )
 
 // validNameRegex enforces alphanumeric-only characters for collection names
 var validNameRegex = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
 
-// IsValidCollectionName checks if a collection name contains only alphanumeric characters
+// IsValidCollectionName checks if a collection name contains only alphanumeric characters,
+// optionally qualified by a named store as in team/utils
 func IsValidCollectionName(name string) error {
+    store, name := splitCollectionName(name)
     if !validNameRegex.MatchString(name) {
         return errors.New("collection name must contain only alphanumeric characters")
     }
+    if store != "" {
+        if _, err := config.NamedStore(store); err != nil {
+            return err
+        }
+    }
     return nil
 }
 
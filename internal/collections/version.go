@@ -0,0 +1,367 @@
+// internal/collections/version.go
+
+package collections
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// Version is a semantic version used to tag collection revisions.
+type Version struct {
+    Major      int
+    Minor      int
+    Patch      int
+    Prerelease string
+}
+
+var versionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?$`)
+var constraintPattern = regexp.MustCompile(`^([\^~=]?)v?(\d+)(?:\.(\d+))?(?:\.(\d+))?$`)
+
+// ParseVersion parses a version tag such as v1.2.0 or 1.2.0-rc.1.
+func ParseVersion(tag string) (Version, error) {
+    match := versionPattern.FindStringSubmatch(tag)
+    if match == nil {
+        return Version{}, fmt.Errorf("invalid version %q: expected v<major>.<minor>.<patch>", tag)
+    }
+    major, _ := strconv.Atoi(match[1])
+    minor, _ := strconv.Atoi(match[2])
+    patch, _ := strconv.Atoi(match[3])
+    return Version{Major: major, Minor: minor, Patch: patch, Prerelease: match[4]}, nil
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is lower than, equal to or higher than other.
+func (v Version) Compare(other Version) int {
+    for _, diff := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+        if diff < 0 {
+            return -1
+        } else if diff > 0 {
+            return 1
+        }
+    }
+
+    // A prerelease sorts before the release it precedes
+    switch {
+    case v.Prerelease == other.Prerelease:
+        return 0
+    case v.Prerelease == "":
+        return 1
+    case other.Prerelease == "":
+        return -1
+    case v.Prerelease < other.Prerelease:
+        return -1
+    }
+    return 1
+}
+
+// Constraint is a range of versions a project accepts, such as ^1.2 or ~1.2.3.
+type Constraint struct {
+    min   Version
+    limit Version // Exclusive upper bound
+    exact bool
+}
+
+// IsVersionConstraint reports whether a collection reference selects a version rather than a revision ID.
+// Bare numbers without dots are taken as revision ID prefixes.
+func IsVersionConstraint(ref string) bool {
+    if ref == "latest" {
+        return true
+    }
+    match := constraintPattern.FindStringSubmatch(ref)
+    return match != nil && (match[1] != "" || match[3] != "" || strings.HasPrefix(ref, "v"))
+}
+
+// ParseConstraint parses a version constraint. ^1.2 accepts versions from 1.2.0 up to 2.0.0,
+// ~1.2 and 1.2 accept 1.2.x, and a full version such as 1.2.3 accepts only that version.
+func ParseConstraint(ref string) (Constraint, error) {
+    if version, err := ParseVersion(strings.TrimPrefix(ref, "=")); err == nil {
+        return Constraint{min: version, exact: true}, nil
+    }
+
+    match := constraintPattern.FindStringSubmatch(ref)
+    if match == nil {
+        return Constraint{}, fmt.Errorf("invalid version constraint %q", ref)
+    }
+    operator := match[1]
+    parts := []int{}
+    for _, part := range match[2:] {
+        if part != "" {
+            number, _ := strconv.Atoi(part)
+            parts = append(parts, number)
+        }
+    }
+    for len(parts) < 3 {
+        parts = append(parts, 0)
+    }
+    min := Version{Major: parts[0], Minor: parts[1], Patch: parts[2]}
+
+    // The limit bumps the component left of the ones allowed to change:
+    // the major version for ^ and for ranges given as a major version only, otherwise the minor version
+    limit := Version{Major: min.Major, Minor: min.Minor + 1}
+    if (operator == "^" && min.Major > 0) || match[3] == "" {
+        limit = Version{Major: min.Major + 1}
+    }
+    return Constraint{min: min, limit: limit}, nil
+}
+
+// Allows reports whether a version satisfies the constraint. Ranges leave out prereleases.
+func (c Constraint) Allows(version Version) bool {
+    if c.exact {
+        return version.Compare(c.min) == 0
+    }
+    return version.Prerelease == "" && version.Compare(c.min) >= 0 && version.Compare(c.limit) < 0
+}
+
+// getTagsPath returns the file mapping version tags to revision IDs for a collection.
+func getTagsPath(collectionName string) string {
+    return filepath.Join(getHistoryPath(collectionName), "TAGS")
+}
+
+// LoadTags returns the version tags of a collection, keyed by tag.
+func LoadTags(collectionName string) (map[string]string, error) {
+    tags := map[string]string{}
+    data, err := os.ReadFile(getTagsPath(collectionName))
+    if os.IsNotExist(err) {
+        return tags, nil
+    } else if err != nil {
+        return nil, fmt.Errorf("failed to read tags of %s: %w", collectionName, err)
+    }
+    if err := yaml.Unmarshal(data, &tags); err != nil {
+        return nil, fmt.Errorf("failed to unmarshal tags of %s: %w", collectionName, err)
+    }
+    return tags, nil
+}
+
+// TagRevision labels a revision of a collection with a version tag. An empty revision tags the latest one.
+func TagRevision(collectionName, tag, revisionID string, force bool) (*Revision, error) {
+    if _, err := ParseVersion(tag); err != nil {
+        return nil, err
+    }
+
+    tags, err := LoadTags(collectionName)
+    if err != nil {
+        return nil, err
+    }
+    if _, exists := tags[tag]; exists && !force {
+        return nil, fmt.Errorf("tag %s already exists in collection %s. Use --force to move it", tag, collectionName)
+    }
+
+    var revision *Revision
+    if revisionID == "" {
+        revision, err = LoadHead(collectionName)
+        if err == nil && revision == nil {
+            err = fmt.Errorf("collection %s has no history to tag", collectionName)
+        }
+    } else {
+        revision, err = LoadRevision(collectionName, revisionID)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    tags[tag] = revision.ID
+    data, err := yaml.Marshal(tags)
+    if err != nil {
+        return nil, fmt.Errorf("failed to marshal tags: %w", err)
+    }
+    if err := writeFileAtomic(getTagsPath(collectionName), bytes.NewReader(data)); err != nil {
+        return nil, fmt.Errorf("failed to write tags of %s: %w", collectionName, err)
+    }
+    return revision, tagGit(collectionName, tag, revision)
+}
+
+// sortedTags returns the tags of a collection ordered from the highest version to the lowest.
+func sortedTags(tags map[string]string) []string {
+    sorted := []string{}
+    versions := map[string]Version{}
+    for tag := range tags {
+        if version, err := ParseVersion(tag); err == nil {
+            sorted = append(sorted, tag)
+            versions[tag] = version
+        }
+    }
+    sort.Slice(sorted, func(i, j int) bool { return versions[sorted[i]].Compare(versions[sorted[j]]) > 0 })
+    return sorted
+}
+
+// resolveConstraint returns the highest tag of a collection allowed by the constraint.
+func resolveConstraint(collectionName, ref string) (string, string, error) {
+    constraint, err := ParseConstraint(ref)
+    if err != nil {
+        return "", "", err
+    }
+    tags, err := LoadTags(collectionName)
+    if err != nil {
+        return "", "", err
+    }
+    for _, tag := range sortedTags(tags) {
+        version, _ := ParseVersion(tag)
+        if constraint.Allows(version) {
+            return tag, tags[tag], nil
+        }
+    }
+    return "", "", fmt.Errorf("no version of collection %s matches %s", collectionName, ref)
+}
+
+// pinnedRevision returns the revision a project is pinned to, or nil if it follows the latest central files.
+// A previewed pin takes the place of the one in the manifest.
+func pinnedRevision(collectionName, projectPath string) (*Revision, error) {
+    if pin := previewedPin(collectionName, projectPath); pin != nil {
+        if pin.Revision == "" {
+            return nil, nil
+        }
+        return loadRevisionFile(collectionName, pin.Revision)
+    }
+    entry, err := findManifestEntry(collectionName, projectPath)
+    if err != nil || entry == nil || entry.Revision == "" {
+        return nil, err
+    }
+    return loadRevisionFile(collectionName, entry.Revision)
+}
+
+// Pin is a change to the version a project is pinned to. It is planned before the files are checked
+// and written along with them, so an aborted require leaves the pin as it was.
+type Pin struct {
+    Collection string
+    Project    string
+    Version    string // Constraint the project is pinned to, empty to follow the latest central files
+    Revision   string // Revision selected by the constraint
+    Tag        string // Tag of the selected revision
+}
+
+// pinKey identifies the project a pin applies to.
+type pinKey struct {
+    collection string
+    project    string
+}
+
+// previewPins holds the pins being previewed, which checks and plans use instead of the manifest.
+var previewPins = map[pinKey]*Pin{}
+
+// previewedPin returns the pin previewed for a project, or nil.
+func previewedPin(collectionName, projectPath string) *Pin {
+    if len(previewPins) == 0 {
+        return nil
+    }
+    if abs, err := filepath.Abs(projectPath); err == nil {
+        projectPath = abs
+    }
+    return previewPins[pinKey{collectionName, projectPath}]
+}
+
+// PlanPin resolves the pin that restricts a project to versions of a collection allowed by the constraint,
+// selecting the highest of them. The constraint "latest" removes the pin so the project follows the latest
+// central files again. Without a constraint, the project's current constraint is resolved again; nil is
+// returned if the project isn't pinned. Nothing is written until the pin is added to a plan.
+func PlanPin(collectionName, projectPath, ref string) (*Pin, error) {
+    projectPath, err := filepath.Abs(projectPath)
+    if err != nil {
+        return nil, fmt.Errorf("could not determine project path: %w", err)
+    }
+    if ref == "" {
+        entry, err := findManifestEntry(collectionName, projectPath)
+        if err != nil || entry == nil || entry.Version == "" {
+            return nil, err
+        }
+        ref = entry.Version
+    }
+
+    pin := &Pin{Collection: collectionName, Project: projectPath}
+    if ref == "latest" {
+        return pin, nil
+    }
+    tag, revisionID, err := resolveConstraint(collectionName, ref)
+    if err != nil {
+        return nil, err
+    }
+    pin.Version, pin.Revision, pin.Tag = ref, revisionID, tag
+    return pin, nil
+}
+
+// Preview makes checks and plans for the project use the pin until the returned function is called.
+func (p *Pin) Preview() func() {
+    key := pinKey{p.Collection, p.Project}
+    previewPins[key] = p
+    return func() {
+        delete(previewPins, key)
+    }
+}
+
+// write stores the pin in the project manifest, leaving it alone if it doesn't change.
+func (p *Pin) write() error {
+    entry, err := findManifestEntry(p.Collection, p.Project)
+    if err != nil || (entry != nil && entry.Version == p.Version && entry.Revision == p.Revision) {
+        return err
+    }
+    return updateManifestEntry(p.Collection, p.Project, func(entry *ManifestEntry) {
+        entry.Version = p.Version
+        entry.Revision = p.Revision
+    })
+}
+
+// UpdatePin moves a pinned project to the highest version allowed by its constraint.
+// It returns the selected tag, or an empty string if the project isn't pinned.
+func UpdatePin(collectionName, projectPath string) (string, error) {
+    pin, err := PlanPin(collectionName, projectPath, "")
+    if err != nil || pin == nil {
+        return "", err
+    }
+    return pin.Tag, pin.write()
+}
+
+// VersionStatus compares the version a project is pinned to with the tagged versions of its collection.
+type VersionStatus struct {
+    Constraint   string `json:"constraint" yaml:"constraint"`
+    Current      string `json:"current,omitempty" yaml:"current,omitempty"`
+    Compatible   string `json:"compatible,omitempty" yaml:"compatible,omitempty"`     // Newer version allowed by the constraint
+    Incompatible string `json:"incompatible,omitempty" yaml:"incompatible,omitempty"` // Newer version outside the constraint
+}
+
+// CheckVersions reports the newer versions available to a pinned project, or nil if it isn't pinned.
+func CheckVersions(collectionName, projectPath string) (*VersionStatus, error) {
+    entry, err := findManifestEntry(collectionName, projectPath)
+    if err != nil || entry == nil || entry.Version == "" {
+        return nil, err
+    }
+    constraint, err := ParseConstraint(entry.Version)
+    if err != nil {
+        return nil, err
+    }
+    tags, err := LoadTags(collectionName)
+    if err != nil {
+        return nil, err
+    }
+
+    status := &VersionStatus{Constraint: entry.Version}
+    var current Version
+    for _, tag := range sortedTags(tags) {
+        if tags[tag] == entry.Revision {
+            status.Current = tag
+            current, _ = ParseVersion(tag)
+            break
+        }
+    }
+
+    for _, tag := range sortedTags(tags) {
+        version, _ := ParseVersion(tag)
+        if status.Current != "" && version.Compare(current) <= 0 {
+            break
+        }
+        if constraint.Allows(version) {
+            if status.Compatible == "" {
+                status.Compatible = tag
+            }
+        } else if status.Incompatible == "" && version.Prerelease == "" {
+            status.Incompatible = tag
+        }
+    }
+    return status, nil
+}
@@ -0,0 +1,378 @@
+// internal/config/config.go
+
+package config
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+
+    "gopkg.in/yaml.v3"
+)
+
+// appName names the directories pst keeps its configuration and storage in.
+const appName = "project-sync-tool"
+
+// Config holds the settings read from the configuration file. Command line flags take precedence over them.
+type Config struct {
+    Store         string              `yaml:"store,omitempty"`          // Storage root, relative paths are resolved against the configuration directory
+    Conflict      string              `yaml:"conflict,omitempty"`       // How push handles files changed on both sides: merge or abort
+    Ignore        []string            `yaml:"ignore,omitempty"`         // Gitignore-style patterns left out of every collection
+    Output        string              `yaml:"output,omitempty"`         // Default output format: text, json or yaml
+    Editor        string              `yaml:"editor,omitempty"`         // Command used to edit the configuration file
+    DiffTool      string              `yaml:"diff-tool,omitempty"`      // Command diff runs with the two files to compare, instead of printing a unified diff
+    Confirm       bool                `yaml:"confirm,omitempty"`        // Ask before overwriting or deleting files with --force, remove, checkout and undo
+    PreserveTimes bool                `yaml:"preserve-times,omitempty"` // Keep modification times when copying, as with --preserve-times
+    Symlinks      string              `yaml:"symlinks,omitempty"`       // Symlink policy of collections that don't set one
+    Stores        map[string]string   `yaml:"stores,omitempty"`         // Named stores, keyed by name, that collections can be qualified with
+    Hooks         map[string][]string `yaml:"hooks,omitempty"`          // Hook -> commands run around require, push and sync for every collection
+}
+
+// Setting describes a key of the configuration file.
+type Setting struct {
+    Key         string
+    Description string
+}
+
+// Settings lists the keys that can be read and changed with Get and Set.
+var Settings = []Setting{
+    {"store", "Storage root for collections and their history"},
+    {"conflict", "How push handles files changed on both sides: merge or abort"},
+    {"ignore", "Gitignore-style patterns left out of every collection"},
+    {"output", "Default output format: text, json or yaml"},
+    {"editor", "Command used by pst config edit"},
+    {"diff-tool", "Command pst diff runs with the central and project file"},
+    {"confirm", "Ask before overwriting or deleting files: true or false"},
+    {"preserve-times", "Keep modification times when copying: true or false"},
+    {"symlinks", "Default symlink policy: follow, keep, skip or error"},
+    {"hooks.pre-require", "Commands run before require changes files, for every collection"},
+    {"hooks.post-require", "Commands run after require changed files, for every collection"},
+    {"hooks.pre-push", "Commands run before push changes files, for every collection"},
+    {"hooks.post-push", "Commands run after push changed files, for every collection"},
+    {"hooks.pre-sync", "Commands run before sync changes files, for every collection"},
+    {"hooks.post-sync", "Commands run after sync changed files, for every collection"},
+}
+
+// storeNameRegex matches valid store names, which prefix collection names.
+var storeNameRegex = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+// storeOverride is the storage root given on the command line, taking precedence over everything else.
+var storeOverride string
+
+// cached keeps the configuration file last read, so it isn't parsed again for every path until it changes.
+var cached struct {
+    path    string
+    modTime time.Time
+    config  Config
+}
+
+// SetStore overrides the storage root, as done by the --store flag.
+func SetStore(path string) error {
+    abs, err := filepath.Abs(expandHome(path))
+    if err != nil {
+        return fmt.Errorf("invalid store path %s: %w", path, err)
+    }
+    storeOverride = abs
+    return nil
+}
+
+// Dir returns the directory holding the configuration file: $XDG_CONFIG_HOME/project-sync-tool,
+// or ~/.config/project-sync-tool if XDG_CONFIG_HOME is not set.
+func Dir() string {
+    base := os.Getenv("XDG_CONFIG_HOME")
+    if base == "" {
+        base = filepath.Join(os.Getenv("HOME"), ".config")
+    }
+    return filepath.Join(base, appName)
+}
+
+// FilePath returns the path of the configuration file.
+func FilePath() string {
+    return filepath.Join(Dir(), "config.yml")
+}
+
+// Load reads the configuration file, returning an empty configuration if it doesn't exist.
+func Load() (Config, error) {
+    path := FilePath()
+    config := Config{}
+    info, err := os.Stat(path)
+    if os.IsNotExist(err) {
+        return config, nil
+    } else if err != nil {
+        return config, fmt.Errorf("failed to read configuration file: %w", err)
+    }
+    if cached.path == path && cached.modTime.Equal(info.ModTime()) {
+        return cached.config, nil
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return config, fmt.Errorf("failed to read configuration file: %w", err)
+    }
+    if err := yaml.Unmarshal(data, &config); err != nil {
+        return config, fmt.Errorf("failed to parse configuration file %s: %w", path, err)
+    }
+    cached.path, cached.modTime, cached.config = path, info.ModTime(), config
+    return config, nil
+}
+
+// Save writes the configuration file.
+func Save(config Config) error {
+    data, err := yaml.Marshal(config)
+    if err != nil {
+        return fmt.Errorf("failed to marshal configuration: %w", err)
+    }
+    if err := os.MkdirAll(Dir(), os.ModePerm); err != nil {
+        return fmt.Errorf("failed to create configuration directory: %w", err)
+    }
+
+    // Replace the file by a rename, so a failed write leaves the previous configuration intact
+    temp, err := os.CreateTemp(Dir(), ".config.yml-")
+    if err != nil {
+        return fmt.Errorf("failed to write configuration file: %w", err)
+    }
+    _, err = temp.Write(data)
+    if closeErr := temp.Close(); err == nil {
+        err = closeErr
+    }
+    if err == nil {
+        err = os.Rename(temp.Name(), FilePath())
+    }
+    if err != nil {
+        os.Remove(temp.Name())
+        return fmt.Errorf("failed to write configuration file: %w", err)
+    }
+    cached.path = ""
+    return nil
+}
+
+// Get returns the value of a setting as text. Lists are returned one item per line.
+func (c Config) Get(key string) (string, error) {
+    switch key {
+    case "store":
+        return c.Store, nil
+    case "conflict":
+        return c.Conflict, nil
+    case "ignore":
+        return strings.Join(c.Ignore, "\n"), nil
+    case "output":
+        return c.Output, nil
+    case "editor":
+        return c.Editor, nil
+    case "diff-tool":
+        return c.DiffTool, nil
+    case "confirm":
+        return strconv.FormatBool(c.Confirm), nil
+    case "preserve-times":
+        return strconv.FormatBool(c.PreserveTimes), nil
+    case "symlinks":
+        return c.Symlinks, nil
+    }
+    if hook, ok := hookSetting(key); ok {
+        return strings.Join(c.Hooks[hook], "\n"), nil
+    }
+    return "", fmt.Errorf("unknown setting %q", key)
+}
+
+// Set changes a setting. No values reset it to its default, and only ignore and hooks take more than one value,
+// each a pattern or a command.
+func (c *Config) Set(key string, values []string) error {
+    if hook, ok := hookSetting(key); ok {
+        if len(values) == 0 {
+            delete(c.Hooks, hook)
+            return nil
+        }
+        if c.Hooks == nil {
+            c.Hooks = map[string][]string{}
+        }
+        c.Hooks[hook] = values
+        return nil
+    }
+
+    value := strings.Join(values, " ")
+    if key != "ignore" && len(values) > 1 {
+        return fmt.Errorf("%s takes a single value", key)
+    }
+
+    var err error
+    switch key {
+    case "store":
+        c.Store = value
+    case "conflict":
+        if err = checkChoice(key, value, "merge", "abort"); err == nil {
+            c.Conflict = value
+        }
+    case "ignore":
+        c.Ignore = values
+    case "output":
+        if err = checkChoice(key, value, "text", "json", "yaml"); err == nil {
+            c.Output = value
+        }
+    case "editor":
+        c.Editor = value
+    case "diff-tool":
+        c.DiffTool = value
+    case "confirm":
+        var b bool
+        if b, err = parseBool(key, value); err == nil {
+            c.Confirm = b
+        }
+    case "preserve-times":
+        var b bool
+        if b, err = parseBool(key, value); err == nil {
+            c.PreserveTimes = b
+        }
+    case "symlinks":
+        if err = checkChoice(key, value, "follow", "keep", "skip", "error"); err == nil {
+            c.Symlinks = value
+        }
+    default:
+        err = fmt.Errorf("unknown setting %q", key)
+    }
+    return err
+}
+
+// hookSetting returns the hook a hooks.<hook> setting configures, if key is one.
+func hookSetting(key string) (string, bool) {
+    if !strings.HasPrefix(key, "hooks.") {
+        return "", false
+    }
+    for _, setting := range Settings {
+        if setting.Key == key {
+            return strings.TrimPrefix(key, "hooks."), true
+        }
+    }
+    return "", false
+}
+
+// checkChoice returns an error unless value is empty or one of choices.
+func checkChoice(key, value string, choices ...string) error {
+    if value == "" {
+        return nil
+    }
+    for _, choice := range choices {
+        if value == choice {
+            return nil
+        }
+    }
+    return fmt.Errorf("invalid %s %q: use %s", key, value, strings.Join(choices, ", "))
+}
+
+// parseBool parses a boolean setting, treating an empty value as false.
+func parseBool(key, value string) (bool, error) {
+    if value == "" {
+        return false, nil
+    }
+    b, err := strconv.ParseBool(value)
+    if err != nil {
+        return false, fmt.Errorf("invalid %s %q: use true or false", key, value)
+    }
+    return b, nil
+}
+
+// StoreRoot returns the directory holding collections, metadata, history and the journal. In order of precedence it is
+// the --store flag, the PST_HOME environment variable, the store setting of the configuration file,
+// $XDG_DATA_HOME/project-sync-tool, or else the configuration directory, where pst has always kept its data.
+// A store already in the configuration directory is kept in use when XDG_DATA_HOME is set.
+func StoreRoot() string {
+    if storeOverride != "" {
+        return storeOverride
+    }
+    if home := os.Getenv("PST_HOME"); home != "" {
+        return expandHome(home)
+    }
+
+    // An unreadable configuration file is reported by Load when the command starts
+    if config, err := Load(); err == nil && config.Store != "" {
+        return resolvePath(config.Store)
+    }
+
+    if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" && !hasStore(Dir()) {
+        return filepath.Join(dataHome, appName)
+    }
+    return Dir()
+}
+
+// NamedStore returns the root of a store added with AddStore.
+func NamedStore(name string) (string, error) {
+    config, err := Load()
+    if err != nil {
+        return "", err
+    }
+    path, ok := config.Stores[name]
+    if !ok {
+        return "", fmt.Errorf("unknown store %s", name)
+    }
+    return resolvePath(path), nil
+}
+
+// AddStore records a named store in the configuration file.
+func AddStore(name, path string) error {
+    if !storeNameRegex.MatchString(name) {
+        return fmt.Errorf("store name must contain only alphanumeric characters")
+    }
+    abs, err := filepath.Abs(expandHome(path))
+    if err != nil {
+        return fmt.Errorf("invalid store path %s: %w", path, err)
+    }
+    config, err := Load()
+    if err != nil {
+        return err
+    }
+    if config.Stores == nil {
+        config.Stores = map[string]string{}
+    }
+    config.Stores[name] = abs
+    return Save(config)
+}
+
+// RemoveStore forgets a named store, leaving its files alone.
+func RemoveStore(name string) error {
+    config, err := Load()
+    if err != nil {
+        return err
+    }
+    if _, ok := config.Stores[name]; !ok {
+        return fmt.Errorf("unknown store %s", name)
+    }
+    delete(config.Stores, name)
+    return Save(config)
+}
+
+// StoreRoots returns the roots of the default store, under an empty name, and of every named store.
+func StoreRoots() map[string]string {
+    roots := map[string]string{"": StoreRoot()}
+    if config, err := Load(); err == nil {
+        for name, path := range config.Stores {
+            roots[name] = resolvePath(path)
+        }
+    }
+    return roots
+}
+
+// resolvePath expands a path from the configuration file, resolving relative paths against the configuration directory.
+func resolvePath(path string) string {
+    path = expandHome(path)
+    if !filepath.IsAbs(path) {
+        path = filepath.Join(Dir(), path)
+    }
+    return path
+}
+
+// hasStore reports whether dir holds collection metadata.
+func hasStore(dir string) bool {
+    info, err := os.Stat(filepath.Join(dir, "meta"))
+    return err == nil && info.IsDir()
+}
+
+// expandHome replaces a leading ~ with the home directory.
+func expandHome(path string) string {
+    if path == "~" || strings.HasPrefix(path, "~/") {
+        return filepath.Join(os.Getenv("HOME"), path[1:])
+    }
+    return path
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestStoreRoot(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    t.Setenv("PST_HOME", "")
+    t.Setenv("XDG_CONFIG_HOME", "")
+    t.Setenv("XDG_DATA_HOME", "")
+
+    if got, want := StoreRoot(), filepath.Join(home, ".config", appName); got != want {
+        t.Errorf("default store = %s, want %s", got, want)
+    }
+
+    t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+    if got, want := StoreRoot(), filepath.Join(home, "data", appName); got != want {
+        t.Errorf("store with XDG_DATA_HOME = %s, want %s", got, want)
+    }
+
+    if err := os.MkdirAll(Dir(), os.ModePerm); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(FilePath(), []byte("store: team\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if got, want := StoreRoot(), filepath.Join(Dir(), "team"); got != want {
+        t.Errorf("configured store = %s, want %s", got, want)
+    }
+
+    t.Setenv("PST_HOME", "~/pst")
+    if got, want := StoreRoot(), filepath.Join(home, "pst"); got != want {
+        t.Errorf("store with PST_HOME = %s, want %s", got, want)
+    }
+
+    if err := SetStore(filepath.Join(home, "flag")); err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { storeOverride = "" })
+    if got, want := StoreRoot(), filepath.Join(home, "flag"); got != want {
+        t.Errorf("store with --store = %s, want %s", got, want)
+    }
+}
+
+func TestSettings(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    t.Setenv("XDG_CONFIG_HOME", "")
+
+    config := Config{}
+    if err := config.Set("ignore", []string{"*.log", "tmp/"}); err != nil {
+        t.Fatal(err)
+    }
+    if err := config.Set("confirm", []string{"true"}); err != nil {
+        t.Fatal(err)
+    }
+    if err := config.Set("conflict", []string{"theirs"}); err == nil {
+        t.Error("accepted an unknown conflict strategy")
+    }
+    if err := config.Set("output", []string{"json", "yaml"}); err == nil {
+        t.Error("accepted two output formats")
+    }
+    if err := Save(config); err != nil {
+        t.Fatal(err)
+    }
+
+    loaded, err := Load()
+    if err != nil {
+        t.Fatal(err)
+    }
+    for key, want := range map[string]string{"ignore": "*.log\ntmp/", "confirm": "true", "conflict": "", "output": ""} {
+        if got, err := loaded.Get(key); err != nil || got != want {
+            t.Errorf("%s = %q, %v, want %q", key, got, err, want)
+        }
+    }
+}
+
+func TestHookSettings(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    t.Setenv("XDG_CONFIG_HOME", "")
+
+    config := Config{}
+    if err := config.Set("hooks.pre-push", []string{"make test", "make lint"}); err != nil {
+        t.Fatal(err)
+    }
+    if err := config.Set("hooks.pre-deploy", []string{"true"}); err == nil {
+        t.Error("accepted an unknown hook")
+    }
+    if err := Save(config); err != nil {
+        t.Fatal(err)
+    }
+
+    // Hooks written by hand load too
+    data, err := os.ReadFile(FilePath())
+    if err != nil {
+        t.Fatal(err)
+    }
+    data = append(data, "    post-sync:\n        - make docs\n"...)
+    if err := os.WriteFile(FilePath(), data, 0644); err != nil {
+        t.Fatal(err)
+    }
+    loaded, err := Load()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := strings.Join(loaded.Hooks["pre-push"], "|"); got != "make test|make lint" {
+        t.Errorf("pre-push hooks = %q, want make test and make lint", got)
+    }
+    if got, err := loaded.Get("hooks.post-sync"); err != nil || got != "make docs" {
+        t.Errorf("hooks.post-sync = %q, %v, want make docs", got, err)
+    }
+    if err := loaded.Set("hooks.pre-push", nil); err != nil || len(loaded.Hooks["pre-push"]) != 0 {
+        t.Errorf("resetting pre-push left %v, %v", loaded.Hooks["pre-push"], err)
+    }
+}
@@ -8,7 +8,7 @@ import (
 
 func main() {
     if err := pst.Execute(); err != nil {
-        fmt.Println(err)
+        fmt.Fprintln(os.Stderr, err)
         os.Exit(1)
     }
 }